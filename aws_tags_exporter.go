@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/golang/glog"
-	acollector "github.com/grapeshot/aws_tags_exporter/collector"
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -35,32 +41,260 @@ func (cs *collectorSet) Set(value string) error {
 	return nil
 }
 
-var globalCollectors = collectorSet{"route53": {}}
+// collectorDurations maps a collector name to a duration.
+type collectorDurations map[string]time.Duration
 
-type registryCollection struct {
-	Registry   *prometheus.Registry
-	Collectors collectorSet
-	Region     *string
+func (cd *collectorDurations) String() string {
+	cSlice := make([]string, 0, len(*cd))
+	for c, d := range *cd {
+		cSlice = append(cSlice, fmt.Sprintf("%s=%s", c, d))
+	}
+
+	return strings.Join(cSlice, ",")
 }
 
-func telemetryServer(registry prometheus.Gatherer, host string, port int) {
-	// Address to listen on for web interface and telemetry
-	listenAddress := net.JoinHostPort(host, strconv.Itoa(port))
-	glog.Infof("Starting telemetry server: %s", listenAddress)
+func (cd *collectorDurations) Set(value string) error {
+	cSlice := strings.Split(value, ",")
+	for _, c := range cSlice {
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("expected <collector>=<duration>, got %q", c)
+		}
 
-	mux := http.NewServeMux()
+		d, err := time.ParseDuration(kv[1])
+		if err != nil {
+			return err
+		}
+		(*cd)[kv[0]] = d
+	}
 
-	// Add metricsPath
-	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return nil
+}
 
-	// Add index
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+// get returns the duration for the collector or def if none was set.
+func (cd collectorDurations) get(collector string, def time.Duration) time.Duration {
+	if d, ok := cd[collector]; ok {
+		return d
+	}
+
+	return def
+}
+
+// collectorInts maps a collector name to an integer.
+type collectorInts map[string]int
+
+func (ci *collectorInts) String() string {
+	cSlice := make([]string, 0, len(*ci))
+	for c, i := range *ci {
+		cSlice = append(cSlice, fmt.Sprintf("%s=%d", c, i))
+	}
+
+	return strings.Join(cSlice, ",")
+}
+
+func (ci *collectorInts) Set(value string) error {
+	cSlice := strings.Split(value, ",")
+	for _, c := range cSlice {
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("expected <collector>=<integer>, got %q", c)
+		}
+
+		i, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return err
+		}
+		(*ci)[kv[0]] = i
+	}
+
+	return nil
+}
+
+// get returns the integer for the collector or def if none was set.
+func (ci collectorInts) get(collector string, def int) int {
+	if i, ok := ci[collector]; ok {
+		return i
+	}
+
+	return def
+}
+
+// regionList is a list of AWS regions.
+type regionList []string
+
+func (rl *regionList) String() string {
+	return strings.Join(*rl, ",")
+}
+
+func (rl *regionList) Set(value string) error {
+	*rl = append(*rl, strings.Split(value, ",")...)
+	return nil
+}
+
+// expand replaces "all" with the regions returned by enabled.
+func (rl regionList) expand(enabled func() ([]string, error)) (regionList, error) {
+	for _, r := range rl {
+		if r != "all" {
+			continue
+		}
+
+		all, err := enabled()
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the enabled regions: %v", err)
+		}
+		if len(all) == 0 {
+			return nil, errors.New("failed to describe the enabled regions: no regions are enabled")
+		}
+		return all, nil
+	}
+
+	return rl, nil
+}
+
+// roleList is a list of IAM roles to assume.
+// Each role is formatted as <arn>[,external_id=<id>][,session_name=<name>][,name=<account name>].
+type roleList []acollector.Role
+
+func (rl *roleList) String() string {
+	rSlice := make([]string, 0, len(*rl))
+	for _, r := range *rl {
+		rSlice = append(rSlice, r.ARN)
+	}
+
+	return strings.Join(rSlice, " ")
+}
+
+func (rl *roleList) Set(value string) error {
+	fields := strings.Split(value, ",")
+	role := acollector.Role{ARN: fields[0]}
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("expected <option>=<value>, got %q", f)
+		}
+
+		switch kv[0] {
+		case "external_id":
+			role.ExternalID = kv[1]
+		case "session_name":
+			role.SessionName = kv[1]
+		case "name":
+			role.Name = kv[1]
+		default:
+			return fmt.Errorf("unknown role option %q", kv[0])
+		}
+	}
+	*rl = append(*rl, role)
+
+	return nil
+}
+
+// tagRules is a list of tag rules that may be repeated on the command line.
+type tagRules []acollector.TagRule
+
+func (tr *tagRules) String() string {
+	rSlice := make([]string, 0, len(*tr))
+	for _, r := range *tr {
+		rSlice = append(rSlice, r.String())
+	}
+
+	return strings.Join(rSlice, " ")
+}
+
+func (tr *tagRules) Set(value string) error {
+	r, err := acollector.ParseTagRule(value)
+	if err != nil {
+		return err
+	}
+	*tr = append(*tr, r)
+
+	return nil
+}
+
+// collectorTagRules maps a collector name to a list of tag rules.
+// Each rule is formatted as <collector>=<rule>.
+type collectorTagRules map[string]tagRules
+
+func (ct *collectorTagRules) String() string {
+	cSlice := make([]string, 0, len(*ct))
+	for c, rules := range *ct {
+		for _, r := range rules {
+			cSlice = append(cSlice, fmt.Sprintf("%s=%s", c, r))
+		}
+	}
+
+	return strings.Join(cSlice, " ")
+}
+
+func (ct *collectorTagRules) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected <collector>=<rule>, got %q", value)
+	}
+
+	rules := (*ct)[kv[0]]
+	if err := rules.Set(kv[1]); err != nil {
+		return err
+	}
+	(*ct)[kv[0]] = rules
+
+	return nil
+}
+
+// stringList is a comma-separated list of strings (e.g. tag keys).
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, strings.Split(value, ",")...)
+	return nil
+}
+
+// collectorTagKeys maps a collector name to a list of tag keys.
+// Each list is formatted as <collector>=<key>[,<key>...].
+type collectorTagKeys map[string]stringList
+
+func (ck *collectorTagKeys) String() string {
+	cSlice := make([]string, 0, len(*ck))
+	for c, keys := range *ck {
+		cSlice = append(cSlice, fmt.Sprintf("%s=%s", c, keys.String()))
+	}
+
+	return strings.Join(cSlice, " ")
+}
+
+func (ck *collectorTagKeys) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected <collector>=<key>[,<key>...], got %q", value)
+	}
+
+	keys := (*ck)[kv[0]]
+	if err := keys.Set(kv[1]); err != nil {
+		return err
+	}
+	(*ck)[kv[0]] = keys
+
+	return nil
+}
+
+// indexHandler serves an index page linking to the paths.
+func indexHandler(title, heading string, paths ...string) http.Handler {
+	var links strings.Builder
+	for _, path := range paths {
+		links.WriteString(`
+             <li><a href='` + path + `'>` + strings.TrimLeft(path, "/-") + `</a></li>`)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, err := w.Write([]byte(`<html>
-             <head><title>AWS Tags Exporter Server</title></head>
+             <head><title>` + title + `</title></head>
              <body>
-             <h1>AWS Tags Exporter Metrics</h1>
-			 <ul>
-             <li><a href='` + "/metrics" + `'>metrics</a></li>
+             <h1>` + heading + `</h1>
+			 <ul>` + links.String() + `
 			 </ul>
              </body>
              </html>`))
@@ -68,70 +302,199 @@ func telemetryServer(registry prometheus.Gatherer, host string, port int) {
 			glog.Fatalf("Write failed: %v", err)
 		}
 	})
-	glog.Fatal(http.ListenAndServe(listenAddress, mux))
 }
 
-func metricsServer(registry prometheus.Gatherer, host string, port int) {
+// telemetryServer creates the server of the exporter's own metrics.
+func telemetryServer(e *exporter, registry prometheus.Gatherer, cfg *config) *http.Server {
 	// Address to listen on for web interface and telemetry
-	listenAddress := net.JoinHostPort(host, strconv.Itoa(port))
-	glog.Infof("Starting metrics server: %s", listenAddress)
+	listenAddress := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.TelemetryPort))
 
 	mux := http.NewServeMux()
 
 	// Add metricsPath
-	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.Handle(cfg.TelemetryPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	// Add health endpoints
+	mux.Handle("/-/healthy", healthyHandler())
+	mux.Handle("/-/ready", readyHandler(e))
 
 	// Add index
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		_, err := w.Write([]byte(`<html>
-             <head><title>AWS Tags Server</title></head>
-             <body>
-             <h1>AWS Tags Metrics</h1>
-			 <ul>
-             <li><a href='` + "/metrics" + `'>metrics</a></li>
-			 </ul>
-             </body>
-             </html>`))
+	mux.Handle("/", indexHandler("AWS Tags Exporter Server", "AWS Tags Exporter Metrics", cfg.TelemetryPath, "/-/healthy", "/-/ready"))
+	return &http.Server{Addr: listenAddress, Handler: mux}
+}
+
+// scrapeTimeout returns the timeout of the scrape from the X-Prometheus-Scrape-Timeout-Seconds header, less the offset.
+// It returns 0 if the header is not set or invalid.
+func scrapeTimeout(r *http.Request, offset time.Duration) time.Duration {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	timeout := time.Duration(seconds*float64(time.Second)) - offset
+	if timeout <= 0 {
+		return 0
+	}
+	return timeout
+}
+
+// filterCollectors returns the collectors named by the collect[] parameters of the request, or every collector if
+// there are none. It returns an error if a named collector is not registered.
+func filterCollectors(r *http.Request, collectors map[string]*acollector.TagsCollector) (map[string]*acollector.TagsCollector, error) {
+	names := r.URL.Query()["collect[]"]
+	if len(names) == 0 {
+		return collectors, nil
+	}
+
+	filtered := make(map[string]*acollector.TagsCollector, len(names))
+	for _, name := range names {
+		collector, ok := collectors[name]
+		if !ok {
+			if _, ok := acollector.AvailableCollectors[name]; ok {
+				return nil, fmt.Errorf("collector %q is not enabled", name)
+			}
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		filtered[name] = collector
+	}
+	return filtered, nil
+}
+
+// gatherErrorLog logs the errors of gathering metrics that are served regardless.
+type gatherErrorLog struct{}
+
+func (gatherErrorLog) Println(v ...interface{}) {
+	glog.Warningln(v...)
+}
+
+// scrapeHandler serves the metrics of the exporter's collectors, abandoning any tags listed during the scrape
+// when the client disconnects or the scrape times out.
+// The collectors can be restricted with collect[] parameters, for example /metrics?collect[]=ec2&collect[]=rds.
+// If telemetry is not nil, its metrics are served alongside the tag metrics, even if gathering the tags fails.
+func scrapeHandler(e *exporter, telemetry prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active, offset := e.active()
+		collectors, err := filterCollectors(r, active)
 		if err != nil {
-			glog.Fatalf("Write failed: %v", err)
+			http.Error(w, fmt.Sprintf("Invalid collect[] parameter: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		if timeout := scrapeTimeout(r, offset); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		registry := prometheus.NewRegistry()
+		for _, collector := range collectors {
+			registry.MustRegister(collector.WithContext(ctx))
+		}
+		if telemetry == nil {
+			promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+			return
 		}
+		// An error gathering either the tags or the telemetry must not hide the other
+		opts := promhttp.HandlerOpts{ErrorLog: gatherErrorLog{}, ErrorHandling: promhttp.ContinueOnError}
+		promhttp.HandlerFor(prometheus.Gatherers{registry, telemetry}, opts).ServeHTTP(w, r)
 	})
-	glog.Fatal(http.ListenAndServe(listenAddress, mux))
 }
 
-// registerCollectors creates and starts informers and initializes and
-// registers metrics for collection.
-func registerCollectors(r registryCollection) []string {
+// metricsServer creates the server of the exporter's tag metrics.
+// In single port mode, it also serves the telemetry of the registry, which is nil otherwise.
+func metricsServer(e *exporter, registry prometheus.Gatherer, cfg *config) *http.Server {
+	// Address to listen on for web interface and telemetry
+	listenAddress := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
 
-	activeCollectors := []string{}
-	for c := range r.Collectors {
-		if collector, ok := acollector.AvailableCollectors[c]; ok {
-			err := collector.Register(r.Registry, *r.Region)
-			if err != nil {
-				glog.Warningf("Failed to initialise collector: %s", c)
-				glog.Warning(err)
-				continue
-			}
-			activeCollectors = append(activeCollectors, c)
-		} else {
-			glog.Warningf("No requested collector: %s", c)
+	mux := http.NewServeMux()
+	paths := []string{cfg.MetricsPath}
+
+	// Add metricsPath, merging the telemetry into it if they share a path
+	if registry != nil && cfg.TelemetryPath == cfg.MetricsPath {
+		mux.Handle(cfg.MetricsPath, scrapeHandler(e, registry))
+	} else {
+		mux.Handle(cfg.MetricsPath, scrapeHandler(e, nil))
+		if registry != nil {
+			mux.Handle(cfg.TelemetryPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			paths = append(paths, cfg.TelemetryPath)
 		}
 	}
 
-	if len(activeCollectors) == 0 {
-		glog.Exit("No valid collectors specified")
+	// Add health endpoints
+	mux.Handle("/-/healthy", healthyHandler())
+	mux.Handle("/-/ready", readyHandler(e))
+
+	// Add probe endpoint
+	mux.Handle("/probe", probeHandler(e))
+
+	// Add reload endpoint
+	mux.Handle("/-/reload", reloadHandler(e))
+
+	// Add index
+	mux.Handle("/", indexHandler("AWS Tags Server", "AWS Tags Metrics", append(paths, "/-/healthy", "/-/ready")...))
+	return &http.Server{Addr: listenAddress, Handler: mux}
+}
+
+// serve starts the named server, exiting the process if it fails for any reason other than being shut down.
+func serve(name string, srv *http.Server) {
+	var err error
+	if srv.TLSConfig != nil {
+		glog.Infof("Starting %s server with TLS: %s", name, srv.Addr)
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		glog.Infof("Starting %s server: %s", name, srv.Addr)
+		err = srv.ListenAndServe()
 	}
+	if err != http.ErrServerClosed {
+		glog.Fatal(err)
+	}
+}
+
+// shutdown gracefully shuts the servers down, waiting up to timeout for in-flight requests to complete
+// before closing them, and then stops the exporter's collectors.
+func shutdown(e *exporter, timeout time.Duration, servers ...*http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				glog.Warningf("Failed to shut down server %s gracefully: %v", srv.Addr, err)
+				srv.Close()
+			}
+		}(srv)
+	}
+	wg.Wait()
 
-	return activeCollectors
+	e.stop()
 }
 
-func getCollectorsAfterExclude(ex collectorSet) collectorSet {
+// availableCollectors returns every available collector, including those that are opt-in.
+func availableCollectors() collectorSet {
 	available := make(collectorSet)
 
 	for col := range acollector.AvailableCollectors {
 		available[col] = struct{}{}
 	}
 
+	return available
+}
+
+// getCollectorsAfterExclude returns the collectors that are enabled by default, other than the excluded ones.
+// Opt-in collectors are only enabled when they are included by name.
+func getCollectorsAfterExclude(ex collectorSet) collectorSet {
+	available := availableCollectors()
+
+	for c, collector := range acollector.AvailableCollectors {
+		if collector.OptIn() {
+			delete(available, c)
+		}
+	}
+
 	for c := range ex {
 		delete(available, c)
 	}
@@ -141,7 +504,7 @@ func getCollectorsAfterExclude(ex collectorSet) collectorSet {
 
 func allCollectorsAreGlobal(cols collectorSet) bool {
 	for col := range cols {
-		if _, ok := globalCollectors[col]; !ok {
+		if collector, ok := acollector.AvailableCollectors[col]; ok && !collector.Global() {
 			return false
 		}
 	}
@@ -150,59 +513,95 @@ func allCollectorsAreGlobal(cols collectorSet) bool {
 }
 
 func main() {
-	// Parse the args (expecting -aws.region)
-	TelemetryPort := flag.Int("web.telemetry-port", 60021, "Port number to listen on for telemetry")
-	Port := flag.Int("web.port", 60020, "Port number to listen on for metrics")
-	Host := flag.String("web.host", "0.0.0.0", "Port number to listen on, default is 0.0.0.0")
-	Region := flag.String("aws.region", "", "AWS region to query")
-
-	Includes := make(collectorSet)
-	flag.Var(&Includes, "include", "Comma-seperated list of collectors to include")
-	Excludes := make(collectorSet)
-	flag.Var(&Excludes, "exclude", "Comma-separated list to exclude from all available collectors")
+	cfg := newConfig()
+	cfg.registerFlags(flag.CommandLine)
+	ConfigFile := flag.String("config.file", "", "Path to a YAML configuration file, flags that are set override its settings")
 
 	List := flag.Bool("list", false, "List all available collectors")
 
 	flag.Parse()
 
 	if *List {
-		cs := getCollectorsAfterExclude(collectorSet{})
+		cs := availableCollectors()
 		fmt.Println("Available Collectors: ")
 		fmt.Println(cs.String())
 		return
 	}
 
-	if len(Includes) != 0 && len(Excludes) != 0 {
-		glog.Exit("Only specify either included or excluded collectors")
+	if *ConfigFile != "" {
+		var err error
+		cfg, err = loadConfig(*ConfigFile, os.Args[1:])
+		if err != nil {
+			glog.Exitf("Failed to load configuration: %v", err)
+		}
+	} else if err := cfg.validate(); err != nil {
+		glog.Exitf("Invalid configuration: %v", err)
 	}
 
-	var cols collectorSet
-
-	if len(Includes) != 0 {
-		cols = Includes
-	} else {
-		cols = getCollectorsAfterExclude(Excludes)
+	var wc *webServerConfig
+	if cfg.WebConfigFile != "" {
+		var err error
+		wc, err = loadWebServerConfig(cfg.WebConfigFile)
+		if err != nil {
+			glog.Exitf("Failed to load web configuration: %v", err)
+		}
 	}
 
-	if *Region == "" && !allCollectorsAreGlobal(cols) {
-		glog.Exit("Please supply a region")
-	}
-
-	collectorRegistry := registryCollection{
-		Registry:   prometheus.NewRegistry(),
-		Collectors: cols,
-		Region:     Region,
-	}
+	acollector.SetRequestLimits(cfg.MaxConcurrency, cfg.MaxConcurrencies, cfg.RequestsPerSecond, cfg.RequestsBurst)
 
 	awsTagsMetricsRegistry := prometheus.NewRegistry()
 	awsTagsMetricsRegistry.MustRegister(acollector.RequestTotalMetric)
 	awsTagsMetricsRegistry.MustRegister(acollector.RequestErrorTotalMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.PagesTotalMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.RequestQueueSecondsMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.RequestRetriesTotalMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.LabelCollisionsTotalMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.CollectorSuccessMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.CollectorDurationMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.CollectorResourcesMetric)
+	awsTagsMetricsRegistry.MustRegister(acollector.CollectorLastSuccessMetric)
+	awsTagsMetricsRegistry.MustRegister(ConfigLastReloadSuccessfulMetric)
+	awsTagsMetricsRegistry.MustRegister(ConfigLastReloadSuccessMetric)
 	awsTagsMetricsRegistry.MustRegister(prometheus.NewProcessCollector(os.Getpid(), ""))
 	awsTagsMetricsRegistry.MustRegister(prometheus.NewGoCollector())
 
-	activeCollectors := registerCollectors(collectorRegistry)
-	glog.Infof("Active collectors: %s", strings.Join(activeCollectors, ","))
-	go telemetryServer(awsTagsMetricsRegistry, *Host, *TelemetryPort)
-	metricsServer(collectorRegistry.Registry, *Host, *Port)
+	e := newExporter(*ConfigFile, os.Args[1:])
+	awsTagsMetricsRegistry.MustRegister(acollector.NewDataAgeCollector(e.tagsCollectors))
+	if err := e.apply(cfg); err != nil {
+		if len(e.collectors) == 0 {
+			glog.Exit(err)
+		}
+		glog.Warning(err)
+		ConfigLastReloadSuccessfulMetric.Set(0)
+	} else {
+		ConfigLastReloadSuccessfulMetric.Set(1)
+		ConfigLastReloadSuccessMetric.Set(float64(time.Now().Unix()))
+	}
+
+	go reloadOnSignal(e)
+	names := []string{"metrics"}
+	var servers []*http.Server
+	if cfg.SinglePort {
+		servers = append(servers, metricsServer(e, awsTagsMetricsRegistry, cfg))
+	} else {
+		names = append(names, "telemetry")
+		servers = append(servers, metricsServer(e, nil, cfg), telemetryServer(e, awsTagsMetricsRegistry, cfg))
+	}
+	if wc != nil {
+		for _, srv := range servers {
+			if err := wc.secure(srv); err != nil {
+				glog.Exit(err)
+			}
+		}
+	}
+	for i, srv := range servers {
+		go serve(names[i], srv)
+	}
 
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, os.Interrupt)
+	sig := <-term
+	glog.Infof("Received %s, shutting down", sig)
+	shutdown(e, cfg.ShutdownTimeout, servers...)
+	glog.Flush()
 }
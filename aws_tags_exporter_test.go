@@ -1,14 +1,24 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 var (
@@ -89,3 +99,289 @@ func killCmd() {
 		C.cmd.Process.Kill()
 	}
 }
+
+// get requests the path from the handler and returns the response body.
+func get(t *testing.T, h http.Handler, path string) string {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET %s: status code should be %d, not %d", path, http.StatusOK, w.Code)
+	}
+	return w.Body.String()
+}
+
+// gathererFunc is a prometheus.Gatherer that calls the function to gather.
+type gathererFunc func() ([]*dto.MetricFamily, error)
+
+func (f gathererFunc) Gather() ([]*dto.MetricFamily, error) {
+	return f()
+}
+
+func TestMetricsServerSinglePort(t *testing.T) {
+	telemetry := prometheus.NewRegistry()
+	telemetry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_telemetry_total", Help: "Test telemetry"}))
+
+	e := newExporter("", nil)
+	e.cfg = newConfig()
+
+	cfg := newConfig()
+	cfg.SinglePort = true
+	if body := get(t, metricsServer(e, telemetry, cfg).Handler, "/metrics"); !strings.Contains(body, "test_telemetry_total") {
+		t.Errorf("Merged /metrics should contain the telemetry, not:\n%s", body)
+	}
+
+	// A failing gather does not hide the metrics that were gathered
+	failing := prometheus.Gatherers{telemetry, gathererFunc(func() ([]*dto.MetricFamily, error) { return nil, errors.New("failed") })}
+	if body := get(t, metricsServer(e, failing, cfg).Handler, "/metrics"); !strings.Contains(body, "test_telemetry_total") {
+		t.Errorf("Merged /metrics should contain the telemetry despite errors, not:\n%s", body)
+	}
+
+	cfg.TelemetryPath = "/telemetry"
+	srv := metricsServer(e, telemetry, cfg)
+	if body := get(t, srv.Handler, "/metrics"); strings.Contains(body, "test_telemetry_total") {
+		t.Errorf("/metrics should not contain the telemetry when it has its own path, not:\n%s", body)
+	}
+	if body := get(t, srv.Handler, "/telemetry"); !strings.Contains(body, "test_telemetry_total") {
+		t.Errorf("/telemetry should contain the telemetry, not:\n%s", body)
+	}
+}
+
+func TestFilterCollectors(t *testing.T) {
+	ec2, rds := acollector.AvailableCollectors["ec2"], acollector.AvailableCollectors["rds"]
+	collectors := map[string]*acollector.TagsCollector{"ec2": &ec2, "rds": &rds}
+
+	tests := []struct {
+		query      string
+		collectors []string
+		err        string
+	}{
+		{query: "", collectors: []string{"ec2", "rds"}},
+		{query: "collect[]=ec2", collectors: []string{"ec2"}},
+		{query: "collect[]=ec2&collect[]=rds", collectors: []string{"ec2", "rds"}},
+		{query: "collect[]=dynamodb", err: `collector "dynamodb" is not enabled`},
+		{query: "collect[]=ec3", err: `unknown collector "ec3"`},
+	}
+
+	for _, test := range tests {
+		filtered, err := filterCollectors(httptest.NewRequest(http.MethodGet, "/metrics?"+test.query, nil), collectors)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: error should contain %q, not %v", test.query, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.query, err)
+			continue
+		}
+
+		names := make([]string, 0, len(filtered))
+		for name := range filtered {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, test.collectors) {
+			t.Errorf("%q: collectors should be %v, not %v", test.query, test.collectors, names)
+		}
+	}
+}
+
+func TestRegionListExpand(t *testing.T) {
+	enabled := func() ([]string, error) { return []string{"eu-west-1", "me-south-1", "us-east-1"}, nil }
+	tests := []struct {
+		regions  regionList
+		enabled  func() ([]string, error)
+		expanded regionList
+		err      string
+	}{
+		{regions: regionList{}, enabled: enabled, expanded: regionList{}},
+		{regions: regionList{"eu-west-1", "us-east-1"}, enabled: enabled, expanded: regionList{"eu-west-1", "us-east-1"}},
+		{regions: regionList{"all"}, enabled: enabled, expanded: regionList{"eu-west-1", "me-south-1", "us-east-1"}},
+		{regions: regionList{"eu-west-1", "all"}, enabled: enabled, expanded: regionList{"eu-west-1", "me-south-1", "us-east-1"}},
+		{regions: regionList{"all"}, enabled: func() ([]string, error) { return nil, errors.New("access denied") }, err: "access denied"},
+		{regions: regionList{"all"}, enabled: func() ([]string, error) { return nil, nil }, err: "no regions are enabled"},
+		// The enabled regions are only described for "all".
+		{regions: regionList{"eu-west-1"}, enabled: func() ([]string, error) { return nil, errors.New("access denied") }, expanded: regionList{"eu-west-1"}},
+	}
+
+	for _, test := range tests {
+		expanded, err := test.regions.expand(test.enabled)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%v: error should contain %q, not %v", test.regions, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.regions, err)
+			continue
+		}
+		if !reflect.DeepEqual(expanded, test.expanded) {
+			t.Errorf("%v: regions should be %v, not %v", test.regions, test.expanded, expanded)
+		}
+	}
+}
+
+func TestGetCollectorsAfterExclude(t *testing.T) {
+	cols := getCollectorsAfterExclude(collectorSet{"ec2": {}})
+	if _, ok := cols["ec2"]; ok {
+		t.Error("Excluded collectors should not be enabled")
+	}
+	if _, ok := cols["rds"]; !ok {
+		t.Error("Collectors that are not excluded should be enabled")
+	}
+	if _, ok := cols["resourcegroupstaggingapi"]; ok {
+		t.Error("Opt-in collectors should only be enabled when they are included")
+	}
+	if _, ok := availableCollectors()["resourcegroupstaggingapi"]; !ok {
+		t.Error("Opt-in collectors should be available")
+	}
+}
+
+func TestScrapeTimeout(t *testing.T) {
+	tests := []struct {
+		header  string
+		offset  time.Duration
+		timeout time.Duration
+	}{
+		{header: "", offset: 500 * time.Millisecond, timeout: 0},
+		{header: "10", offset: 500 * time.Millisecond, timeout: 9500 * time.Millisecond},
+		{header: "2.5", offset: 0, timeout: 2500 * time.Millisecond},
+		{header: "0.25", offset: 100 * time.Millisecond, timeout: 150 * time.Millisecond},
+		// An offset that consumes the whole scrape timeout leaves the scrape unbounded
+		{header: "0.5", offset: 500 * time.Millisecond, timeout: 0},
+		{header: "0.5", offset: time.Second, timeout: 0},
+		{header: "ten", offset: 0, timeout: 0},
+		{header: "0", offset: 0, timeout: 0},
+		{header: "-10", offset: 0, timeout: 0},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if test.header != "" {
+			r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", test.header)
+		}
+		if timeout := scrapeTimeout(r, test.offset); timeout != test.timeout {
+			t.Errorf("%q less %s: timeout should be %s, not %s", test.header, test.offset, test.timeout, timeout)
+		}
+	}
+}
+
+// blockingTransport is a fake AWS that holds every request until it is cancelled, or released if its host starts with
+// a released service, and reports the hosts of the requests it received and cancelled.
+type blockingTransport struct {
+	released string
+	release  chan struct{}
+	received chan string
+	canceled chan string
+}
+
+func (bt *blockingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	bt.received <- r.URL.Host
+	if strings.HasPrefix(r.URL.Host, bt.released+".") {
+		select {
+		case <-bt.release:
+			return nil, errors.New("released")
+		case <-r.Context().Done():
+		}
+	} else {
+		<-r.Context().Done()
+	}
+	bt.canceled <- r.URL.Host
+	return nil, r.Context().Err()
+}
+
+// awaitHost waits for a host starting with service to be sent on hosts.
+func awaitHost(t *testing.T, hosts chan string, service, what string) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case host := <-hosts:
+			if strings.HasPrefix(host, service+".") {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	// The SDK sends requests with http.DefaultClient, unless a CA bundle replaces its transport
+	bt := &blockingTransport{released: "rds", release: make(chan struct{}), received: make(chan string, 100), canceled: make(chan string, 100)}
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = bt
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	cfg := newConfig()
+	cfg.Includes = collectorSet{"ec2": {}, "rds": {}}
+	cfg.Regions = regionList{"eu-west-1"}
+	cfg.MaxRetries = 0
+	// ec2 is refreshed in the background and rds is listed by the scrape
+	cfg.RefreshIntervals = collectorDurations{"ec2": time.Hour, "rds": 0}
+	e := newExporter("", nil)
+	e.newAccounts = func([]acollector.Role) ([]acollector.Account, error) {
+		return []acollector.Account{{ID: "123456789012"}}, nil
+	}
+	if err := e.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	awaitHost(t, bt.received, "ec2", "the background refresh")
+
+	srv := metricsServer(e, nil, cfg)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+
+	client := &http.Client{Transport: &http.Transport{}}
+	scraped := make(chan int, 1)
+	go func() {
+		resp, err := client.Get("http://" + l.Addr().String() + "/metrics?collect[]=rds")
+		if err != nil {
+			t.Errorf("The scrape in flight should complete: %v", err)
+			scraped <- 0
+			return
+		}
+		resp.Body.Close()
+		scraped <- resp.StatusCode
+	}()
+	awaitHost(t, bt.received, "rds", "the scrape")
+
+	stopped := make(chan struct{})
+	go func() {
+		shutdown(e, 10*time.Second, srv)
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Shutdown should wait for the scrape in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if resp, err := client.Get("http://" + l.Addr().String() + "/-/healthy"); err == nil {
+		resp.Body.Close()
+		t.Error("New requests should be refused once shutting down")
+	}
+
+	close(bt.release)
+	select {
+	case code := <-scraped:
+		if code != http.StatusOK {
+			t.Errorf("The scrape in flight should succeed, not respond %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The scrape in flight should complete")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown should return once the scrape in flight completes")
+	}
+
+	awaitHost(t, bt.canceled, "ec2", "the background refresh to be cancelled")
+}
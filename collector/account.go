@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// assumeRoleExpiryWindow is how long before they expire assumed role credentials are refreshed
+	assumeRoleExpiryWindow = time.Minute
+)
+
+// Role is an IAM role that is assumed to list the tags of another AWS account.
+type Role struct {
+	ARN         string
+	ExternalID  string // ExternalID is optional
+	SessionName string // SessionName is optional, the SDK generates one if it is empty
+	Name        string // Name is optional, it identifies the account in probes
+}
+
+// configure configures the provider of the role's credentials with its external ID and session name.
+func (r Role) configure(p *stscreds.AssumeRoleProvider) {
+	if r.ExternalID != "" {
+		p.ExternalID = aws.String(r.ExternalID)
+	}
+	p.RoleSessionName = r.SessionName
+	p.ExpiryWindow = assumeRoleExpiryWindow
+}
+
+// Account is an AWS account that tags are listed in.
+type Account struct {
+	ID          string
+	Name        string                   // Name is the name of the role's account, if any
+	credentials *credentials.Credentials // credentials are nil when using the default credential chain
+}
+
+// NewAccounts returns one Account per role, using the default credential chain to assume them.
+// If no roles are specified, it returns the account of the default credential chain.
+// Assumed role credentials are refreshed automatically before they expire.
+func NewAccounts(roles []Role) ([]Account, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) == 0 {
+		id, err := getAccountID(sess)
+		if err != nil {
+			return nil, err
+		}
+		return []Account{{ID: id}}, nil
+	}
+
+	accounts := make([]Account, 0, len(roles))
+	for _, role := range roles {
+		parsed, err := arn.Parse(role.ARN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid role ARN %q: %v", role.ARN, err)
+		}
+
+		creds := stscreds.NewCredentials(sess, role.ARN, role.configure)
+		accounts = append(accounts, Account{ID: parsed.AccountID, Name: role.Name, credentials: creds})
+	}
+
+	return accounts, nil
+}
+
+// EnabledRegions returns the sorted regions that are enabled in the account of the default credential chain.
+// The regions are described in the default region of the SDK, or in us-east-1 if there is none.
+func EnabledRegions() ([]string, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	region := aws.StringValue(sess.Config.Region)
+	if region == "" {
+		region = endpoints.UsEast1RegionID
+	}
+	out, err := ec2.New(sess, aws.NewConfig().WithRegion(region)).DescribeRegions(&ec2.DescribeRegionsInput{})
+
+	RequestTotalMetric.With(prometheus.Labels{"service": "ec2", "region": region}).Inc()
+	if err != nil {
+		RequestErrorTotalMetric.With(prometheus.Labels{"service": "ec2", "region": region}).Inc()
+		return nil, err
+	}
+
+	regions := make([]string, 0, len(out.Regions))
+	for _, r := range out.Regions {
+		regions = append(regions, aws.StringValue(r.RegionName))
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// session creates a session for the account in the specified region that retries requests with the retryer.
+// Every request sent with the session waits for the limits set by SetRequestLimits for the retryer's service.
+// An empty region leaves the region to the SDK (e.g. for global services).
+func (a Account) session(region string, r retryer) (*session.Session, error) {
+	cfg := aws.NewConfig().WithCredentials(a.credentials)
+	cfg = request.WithRetryer(cfg, r)
+	// Always classify errors with the retryer rather than the SDK's handlers.
+	cfg.EnforceShouldRetryCheck = aws.Bool(true)
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sess.Handlers.Send.Swap(corehandlers.SendHandler.Name, limitedSendHandler(r.service, corehandlers.SendHandler.Fn))
+	return sess, nil
+}
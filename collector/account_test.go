@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+)
+
+func TestNewAccounts(t *testing.T) {
+	roles := []Role{
+		{ARN: "arn:aws:iam::123456789012:role/tags-reader", Name: "prod"},
+		{ARN: "arn:aws:iam::210987654321:role/tags-reader", ExternalID: "secret", SessionName: "aws-tags-exporter"},
+	}
+	// Assuming the roles is deferred until their credentials are used, so no request is sent to AWS.
+	accounts, err := NewAccounts(roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids, names []string
+	for _, a := range accounts {
+		ids, names = append(ids, a.ID), append(names, a.Name)
+		if a.credentials == nil {
+			t.Errorf("%s: the account should use the credentials of its role", a.ID)
+		}
+	}
+	if want := []string{"123456789012", "210987654321"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Account IDs should be parsed from the role ARNs %v, not %v", want, ids)
+	}
+	if want := []string{"prod", ""}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Account names should be %q, not %q", want, names)
+	}
+
+	for _, arn := range []string{"tags-reader", "arn:aws:iam::123456789012"} {
+		if _, err := NewAccounts([]Role{{ARN: arn}}); err == nil || !strings.Contains(err.Error(), "invalid role ARN") {
+			t.Errorf("%s: an invalid role ARN should fail, not %v", arn, err)
+		}
+	}
+}
+
+func TestRoleConfigure(t *testing.T) {
+	tests := []struct {
+		role        Role
+		externalID  *string
+		sessionName string
+	}{
+		{role: Role{ARN: "arn:aws:iam::123456789012:role/tags-reader"}},
+		{
+			role:        Role{ARN: "arn:aws:iam::123456789012:role/tags-reader", ExternalID: "secret", SessionName: "aws-tags-exporter"},
+			externalID:  aws.String("secret"),
+			sessionName: "aws-tags-exporter",
+		},
+	}
+
+	for _, test := range tests {
+		var p stscreds.AssumeRoleProvider
+		test.role.configure(&p)
+		if !reflect.DeepEqual(p.ExternalID, test.externalID) {
+			t.Errorf("%+v: external ID should be %v, not %v", test.role, aws.StringValue(test.externalID), aws.StringValue(p.ExternalID))
+		}
+		if p.RoleSessionName != test.sessionName {
+			t.Errorf("%+v: session name should be %q, not %q", test.role, test.sessionName, p.RoleSessionName)
+		}
+		if p.ExpiryWindow != assumeRoleExpiryWindow {
+			t.Errorf("%+v: credentials should be refreshed %s before they expire, not %s", test.role, assumeRoleExpiryWindow, p.ExpiryWindow)
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package collector
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"context"
+
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -12,56 +13,73 @@ var (
 )
 
 var autoscalingCollector = TagsCollector{
+	service:       "autoscaling",
 	name:          prometheus.BuildFQName(namespace, "autoscaling", "tags"),
 	help:          "AWS autoscaling tags converted to Prometheus labels.",
 	defaultLabels: []string{"autoscaling_group_name", "region"},
-	lister:        &autoscalingLister{},
+	newLister:     func() tagsLister { return newAutoScalingLister(nil) },
 }
 
 type autoscalingLister struct {
-	region  string
-	session *autoscaling.AutoScaling
+	listerConfig
+	session autoscalingiface.AutoScalingAPI
 }
 
-func (al *autoscalingLister) Initialise(region string) (err error) {
-	al.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &al.region})
-	if err != nil {
-		return
-	}
-	al.session = autoscaling.New(sess)
-	return
+// newAutoScalingLister creates an autoscalingLister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newAutoScalingLister(client autoscalingiface.AutoScalingAPI) *autoscalingLister {
+	return &autoscalingLister{session: client}
 }
 
-func (al *autoscalingLister) List() ([]tags, error) {
-
-	out, err := al.session.DescribeTags(&autoscaling.DescribeTagsInput{MaxRecords: &autoscalingMaxRecords})
-	RequestTotalMetric.With(prometheus.Labels{"service": "autoscaling", "region": al.region}).Inc()
-	if err != nil {
-		RequestErrorTotalMetric.With(prometheus.Labels{"service": "autoscaling", "region": al.region}).Inc()
-		return []tags{}, err
+func (al *autoscalingLister) Initialise(cfg listerConfig) error {
+	al.listerConfig = cfg
+	if al.session == nil {
+		al.session = autoscaling.New(cfg.sess)
 	}
+	return nil
+}
+
+func (al *autoscalingLister) List(ctx context.Context) ([]tags, error) {
 
 	// convert to temporary map
 	tagMap := make(map[string]tags, 0)
-	for _, tagDesc := range out.Tags {
-		ts, ok := tagMap[*tagDesc.ResourceId]
-		if !ok {
-			ts = tags{make([]string, 0), make([]string, 0)}
+	pages := 0
+	err := al.session.DescribeTagsPagesWithContext(ctx, &autoscaling.DescribeTagsInput{MaxRecords: &autoscalingMaxRecords}, func(out *autoscaling.DescribeTagsOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "autoscaling", "region": al.region}).Inc()
+		for _, tagDesc := range out.Tags {
+			ts, ok := tagMap[*tagDesc.ResourceId]
+			if !ok {
+				ts = tags{make([]string, 0), make([]string, 0)}
+			}
+
+			ts.keys = append(ts.keys, *tagDesc.Key)
+			ts.values = append(ts.values, *tagDesc.Value)
+			tagMap[*tagDesc.ResourceId] = ts
 		}
 
-		ts.keys = append(ts.keys, *tagDesc.Key)
-		ts.values = append(ts.values, *tagDesc.Value)
-		tagMap[*tagDesc.ResourceId] = ts
+		return al.nextPage("autoscaling", &pages, lastPage)
+	})
+
+	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "autoscaling", "region": al.region}).Inc()
+		RequestErrorTotalMetric.With(prometheus.Labels{"service": "autoscaling", "region": al.region}).Inc()
+		return []tags{}, err
 	}
 
 	// build []tags
 	tagsList := make([]tags, 0, len(tagMap))
 	for k, v := range tagMap {
-		v.keys = append(v.keys, autoscalingCollector.defaultLabels...)
-		v.values = append(v.values, k, al.region)
+		ts := tags{
+			make([]string, 0, len(v.keys)+len(autoscalingCollector.defaultLabels)),
+			make([]string, 0, len(v.keys)+len(autoscalingCollector.defaultLabels)),
+		}
+
+		ts.keys = append(ts.keys, autoscalingCollector.defaultLabels...)
+		ts.values = append(ts.values, k, al.region)
+		ts.keys = append(ts.keys, v.keys...)
+		ts.values = append(ts.values, v.values...)
 
-		tagsList = append(tagsList, v)
+		tagsList = append(tagsList, ts)
 	}
 	return tagsList, nil
 }
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+)
+
+type fakeAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+	pages [][]*autoscaling.TagDescription
+	err   error
+}
+
+func (f *fakeAutoScaling) DescribeTagsPagesWithContext(ctx aws.Context, in *autoscaling.DescribeTagsInput, fn func(*autoscaling.DescribeTagsOutput, bool) bool, opts ...request.Option) error {
+	if f.err != nil {
+		return f.err
+	}
+	for i, page := range f.pages {
+		if !fn(&autoscaling.DescribeTagsOutput{Tags: page}, i == len(f.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func autoscalingTag(group, key, value string) *autoscaling.TagDescription {
+	return &autoscaling.TagDescription{ResourceId: aws.String(group), Key: aws.String(key), Value: aws.String(value)}
+}
+
+func TestAutoScalingLister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name: "groups across pages",
+			lister: newAutoScalingLister(&fakeAutoScaling{pages: [][]*autoscaling.TagDescription{
+				{autoscalingTag("web", "team", "infra"), autoscalingTag("workers", "team", "data")},
+				{autoscalingTag("web", "env", "prod")},
+			}}),
+			resources: []string{
+				"autoscaling_group_name=web,region=eu-west-1,team=infra,env=prod",
+				"autoscaling_group_name=workers,region=eu-west-1,team=data",
+			},
+		},
+		{
+			name:   "failed request",
+			lister: newAutoScalingLister(&fakeAutoScaling{err: errFake}),
+			err:    true,
+		},
+	})
+}
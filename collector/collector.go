@@ -1,8 +1,16 @@
 package collector
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -28,92 +36,601 @@ var (
 		},
 		[]string{"service", "region"},
 	)
+	// PagesTotalMetric counts the total pages fetched from paginated AWS APIs by all collectors
+	PagesTotalMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aws_tags_pages_total",
+			Help: "Total pages fetched by the aws_tags_exporter for a service",
+		},
+		[]string{"service", "region"},
+	)
+	// RequestQueueSecondsMetric observes how long requests waited for the concurrency and rate limits
+	RequestQueueSecondsMetric = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aws_tags_request_queue_seconds",
+			Help:    "Time requests spent waiting for the concurrency and rate limits before being sent",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"service"},
+	)
+	// RequestRetriesTotalMetric counts the requests to AWS retried by all collectors
+	RequestRetriesTotalMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aws_tags_request_retries_total",
+			Help: "Total requests retried by the aws_tags_exporter for a service, by AWS error code",
+		},
+		[]string{"service", "region", "code"},
+	)
+	// LabelCollisionsTotalMetric counts the tags whose keys collided with another label when they were collected
+	LabelCollisionsTotalMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aws_tags_label_collisions_total",
+			Help: "Total tags whose label name collided with another label when collected",
+		},
+		[]string{"collector"},
+	)
+	// CollectorSuccessMetric reports whether the last collection of a collector succeeded
+	CollectorSuccessMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aws_tags_collector_success",
+			Help: "Whether the last collection of a collector succeeded",
+		},
+		[]string{"collector", "region", "account_id"},
+	)
+	// CollectorDurationMetric reports how long the last collection of a collector took
+	CollectorDurationMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aws_tags_collector_duration_seconds",
+			Help: "Duration of the last collection of a collector",
+		},
+		[]string{"collector", "region", "account_id"},
+	)
+	// CollectorResourcesMetric reports how many resources the last successful collection of a collector returned
+	CollectorResourcesMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aws_tags_collector_resources",
+			Help: "Number of resources returned by the last successful collection of a collector",
+		},
+		[]string{"collector", "region", "account_id"},
+	)
+	// CollectorLastSuccessMetric reports when a collector last collected successfully
+	CollectorLastSuccessMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aws_tags_collector_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful collection of a collector",
+		},
+		[]string{"collector", "region", "account_id"},
+	)
 	invalidLabelCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 )
 
+// tags are the labels of a single resource.
+// The keys start with the collector's default labels, followed by the resource's tag keys as returned by AWS.
 type tags struct {
 	keys   []string
 	values []string
 }
 
-// sanitizeKeys is a helper function to convert label keys which have characters not accepted by prometheus to "_"
-func (ls *tags) sanitizeKeys() {
-	for i := range ls.keys {
-		ls.keys[i] = invalidLabelCharRE.ReplaceAllString(ls.keys[i], "_")
+// sendToPrometheus creates a metric for each of the tags and sends them to the specified channel.
+// Tag keys are converted to label names according to the collector's options.
+// Every metric has the same label names, as a Prometheus registry requires of the series of a metric:
+// in schema mode those declared by Describe, otherwise the labels of every resource's tags,
+// with an empty value for the tags that a resource does not have.
+// Tags that cannot be converted to a valid metric are logged and skipped.
+func (tc *TagsCollector) sendToPrometheus(ch chan<- prometheus.Metric, tagsList []tags) {
+	n := len(tc.labels())
+	if len(tc.labelOpts.schema) > 0 {
+		for _, ls := range tagsList {
+			tc.sendMetric(ch, tc.defaultDesc, ls.schemaValues(n, tc.labelOpts.schema))
+		}
+		return
+	}
+
+	keys := make([][]string, len(tagsList))
+	values := make([][]string, len(tagsList))
+	var collisions int
+	used := make(map[string]struct{})
+	for i := range tagsList {
+		var c int
+		keys[i], values[i], c = tagsList[i].labels(n, tc.labelOpts)
+		collisions += c
+		for _, k := range keys[i][n:] {
+			used[k] = struct{}{}
+		}
+	}
+	if collisions > 0 {
+		LabelCollisionsTotalMetric.With(prometheus.Labels{"collector": tc.service}).Add(float64(collisions))
+	}
+
+	names := make([]string, 0, len(used))
+	for k := range used {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	// index maps a tag label name to its position in the label names of every metric
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = n + i
+	}
+
+	desc := prometheus.NewDesc(tc.name, tc.help, append(tc.labels(), names...), nil)
+	for i := range tagsList {
+		labelValues := append(make([]string, 0, n+len(names)), values[i][:n]...)
+		labelValues = append(labelValues, make([]string, len(names))...)
+		for j := n; j < len(keys[i]); j++ {
+			labelValues[index[keys[i][j]]] = values[i][j]
+		}
+		tc.sendMetric(ch, desc, labelValues)
 	}
 }
 
-// sendToPrometheus creates a new metric and sends it to the specified channel
-func (ls *tags) sendToPrometheus(ch chan<- prometheus.Metric, name, help string) {
-	ls.sanitizeKeys()
-	desc := prometheus.NewDesc(
-		name,
-		help,
-		ls.keys,
-		nil,
-	)
+// sendMetric sends a metric with the label values to the specified channel, logging any error.
+func (tc *TagsCollector) sendMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, values []string) {
+	metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	if err != nil {
+		glog.Warningf("Skipping %s metric: %v", tc.name, err)
+		return
+	}
+	ch <- metric
+}
 
-	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, ls.values...)
+// listerConfig is the configuration shared by all tagsListers.
+type listerConfig struct {
+	region        string           // region is the AWS region to list tags in
+	accountID     string           // accountID is the AWS account to list tags in
+	sess          *session.Session // sess is the session used to create AWS clients for the region and account
+	maxPages      int              // maxPages is the maximum number of pages fetched by a paginated request, 0 is unlimited
+	resourceTypes []string         // resourceTypes filter the resources listed by the Resource Groups Tagging API, empty lists every type
 }
 
 type tagsLister interface {
 	// Initialise initialises with any state required to collect tags
 	// It is run exactly once, when a TagsCollector is registered.
-	Initialise(region string) error
+	Initialise(cfg listerConfig) error
 	// List is called on an initialised tagsLister to get the tags
-	// It is run every time the tags are collected.
-	List() ([]tags, error)
+	// It is run every time the tags are refreshed and must stop when ctx is done.
+	List(ctx context.Context) ([]tags, error)
+}
+
+// tagsCache holds the last successful result of a tagsLister.
+// It is safe for concurrent use.
+type tagsCache struct {
+	mu       sync.RWMutex
+	tagsList []tags
+}
+
+func (c *tagsCache) get() []tags {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tagsList
+}
+
+func (c *tagsCache) set(tagsList []tags) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tagsList = tagsList
+}
+
+// listing is a listing of a target's tags that is shared by the collections waiting for it.
+type listing struct {
+	done    chan struct{} // done is closed once the listing has completed
+	tags    []tags
+	err     error
+	waiters int                // waiters are the collections waiting for the listing (guarded by the target's mu)
+	cancel  context.CancelFunc // cancel abandons the listing once no collection is waiting for it
+}
+
+// target is a tagsLister for a single region and account along with the tags it last listed.
+type target struct {
+	region    string
+	accountID string
+	lister    tagsLister
+	cache     tagsCache // cache holds the tags of the last successful listing
+
+	mu           sync.Mutex
+	listing      *listing  // listing is the listing in progress, shared between concurrent collections (nil if there is none)
+	lastSuccess  time.Time // lastSuccess is when the tags were last listed successfully (zero if they never were)
+	lastErr      error     // lastErr is the error of the last listing, nil if it succeeded
+	failingSince time.Time // failingSince is when listing first failed after the last success (zero if the last listing succeeded)
+	retired      bool      // retired is true once the target is no longer collected, its health is then no longer recorded
 }
 
 // TagsCollector is a struct which represents a prometheus Collector
 // It is initialised once per resource type.
+// Once initialised, it is safe for concurrent use.
 type TagsCollector struct {
-	name          string           // name of collector
-	help          string           // help message of collector
-	defaultLabels []string         // defaultLabels are the required labels that a collector must return
-	defaultDesc   *prometheus.Desc // defaultDesc is the prometheus description (initialised on the first Describe call)
-	lister        tagsLister       // lister is used to get the tags for a particular resource
+	service              string            // service is the short name of the collector (e.g. ec2)
+	name                 string            // name of collector
+	help                 string            // help message of collector
+	defaultLabels        []string          // defaultLabels are the required labels that a collector must return
+	defaultDesc          *prometheus.Desc  // defaultDesc is the prometheus description (initialised on Register)
+	global               bool              // global is true if the resource is region agnostic (e.g. Route53)
+	optIn                bool              // optIn is true if the collector is only enabled when it is included by name
+	newLister            func() tagsLister // newLister creates the lister used to get the tags for a particular resource
+	targets              []*target         // targets are the regions and accounts the collector lists tags in (initialised on Register)
+	labelOpts            labelOptions      // labelOpts configure how tags are converted to labels (initialised on Register)
+	schemaLabels         []string          // schemaLabels are the label names of the tag schema (initialised on Register)
+	timeout              time.Duration     // timeout bounds every listing of the tags, 0 is unbounded (initialised on Register)
+	gracePeriod          time.Duration     // gracePeriod is how long the last listed tags are served after listing fails (initialised on Register)
+	background           bool              // background is true if the tags are refreshed in the background (initialised on Register)
+	disableHealthMetrics bool              // disableHealthMetrics is true if the aws_tags_collector_* gauges are not set (initialised on Register)
+	stop                 func()            // stop cancels the background refreshes (nil when the tags are listed on every scrape)
+}
+
+// Global reports whether the collector is region agnostic and so is only listed once.
+func (tc TagsCollector) Global() bool {
+	return tc.global
+}
+
+// OptIn reports whether the collector is only enabled when it is included by name.
+func (tc TagsCollector) OptIn() bool {
+	return tc.optIn
+}
+
+// labels returns the labels of every series: the account_id followed by the defaultLabels.
+func (tc *TagsCollector) labels() []string {
+	return append([]string{"account_id"}, tc.defaultLabels...)
+}
+
+// desc returns the description of the collector's metric.
+// In schema mode, it declares the label of every tag in the schema.
+func (tc *TagsCollector) desc() *prometheus.Desc {
+	return prometheus.NewDesc(tc.name, tc.help, append(tc.labels(), tc.schemaLabels...), nil)
 }
 
 // Describe is required to implement the prometheus.Collector interface.
-// It also initialises defaultDesc when it is first called.
 func (tc *TagsCollector) Describe(ch chan<- *prometheus.Desc) {
 	if tc.defaultDesc == nil {
-		tc.defaultDesc = prometheus.NewDesc(tc.name, tc.help, tc.defaultLabels, nil)
+		// The collector has not been initialised
+		ch <- tc.desc()
+		return
 	}
 	ch <- tc.defaultDesc
 }
 
 // Collect is required to implement the prometheus.Collector interface.
+// The tags of every target are sent under the same metric name.
 func (tc *TagsCollector) Collect(ch chan<- prometheus.Metric) {
-	tagsList, err := tc.lister.List()
+	tc.collect(context.Background(), ch)
+}
+
+// WithContext returns a prometheus.Collector that collects the tags like the TagsCollector,
+// but stops listing them from AWS when ctx is done (e.g. when a scrape times out).
+func (tc *TagsCollector) WithContext(ctx context.Context) prometheus.Collector {
+	return contextCollector{TagsCollector: tc, ctx: ctx}
+}
+
+// contextCollector is a TagsCollector bound to the context of a single scrape.
+type contextCollector struct {
+	*TagsCollector
+	ctx context.Context
+}
+
+// Collect is required to implement the prometheus.Collector interface.
+func (cc contextCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.collect(cc.ctx, ch)
+}
+
+// collect sends the tags of every target to the channel, listing them within ctx when they are not refreshed in the background.
+func (tc *TagsCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	lists := make([][]tags, len(tc.targets))
+	var wg sync.WaitGroup
+	wg.Add(len(tc.targets))
+	for i, t := range tc.targets {
+		go func(i int, t *target) {
+			defer wg.Done()
+			lists[i] = tc.tags(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	// The series of every target share a label set, so they are sent together
+	var tagsList []tags
+	for _, l := range lists {
+		tagsList = append(tagsList, l...)
+	}
+	tc.sendToPrometheus(ch, tagsList)
+}
+
+// tags returns the tags of the target, listing them from AWS first unless the collector refreshes in the background.
+// If listing fails, the tags of the last successful listing are returned until the grace period has expired.
+func (tc *TagsCollector) tags(ctx context.Context, t *target) []tags {
+	if !tc.background {
+		if tagsList, err := tc.list(ctx, t); err == nil {
+			return tagsList
+		}
+	}
+
+	if t.expired(tc.gracePeriod) {
+		return nil
+	}
+	return t.cache.get()
+}
+
+// list gets the tags of the target, sharing any listing already in progress for another collection.
+// A shared listing is detached from the context of the collection that started it, so it is only bound by the
+// collector's timeout. Each collection stops waiting for the tags when its own ctx is done, and the listing is
+// abandoned once no collection is waiting for it.
+// The returned tags must not be modified.
+func (tc *TagsCollector) list(ctx context.Context, t *target) ([]tags, error) {
+	t.mu.Lock()
+	l := t.listing
+	if l == nil {
+		listCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		l = &listing{done: make(chan struct{}), cancel: cancel}
+		t.listing = l
+		go func() {
+			defer cancel()
+			l.tags, l.err = tc.listOnce(listCtx, t)
+
+			t.mu.Lock()
+			if t.listing == l {
+				t.listing = nil
+			}
+			t.mu.Unlock()
+			close(l.done)
+		}()
+	}
+	l.waiters++
+	t.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.tags, l.err
+	case <-ctx.Done():
+		t.mu.Lock()
+		l.waiters--
+		if l.waiters == 0 {
+			// Later collections start a new listing rather than joining the abandoned one
+			l.cancel()
+			if t.listing == l {
+				t.listing = nil
+			}
+		}
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// listOnce gets the tags from the target's lister, labels them with the account and caches them.
+// It also records the health of the collection, unless the listing failed because ctx is done.
+// Listing is abandoned when ctx is done or the collector's timeout expires, whichever is first.
+func (tc *TagsCollector) listOnce(ctx context.Context, t *target) ([]tags, error) {
+	listCtx := ctx
+	if tc.timeout > 0 {
+		var cancel context.CancelFunc
+		listCtx, cancel = context.WithTimeout(ctx, tc.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	tagsList, err := t.lister.List(listCtx)
+	if err != nil && ctx.Err() != nil {
+		// The listing was abandoned, which says nothing about the health of the target
+		return nil, err
+	}
+	t.record(err)
+	if !tc.disableHealthMetrics {
+		t.recordHealth(tc.service, time.Since(start), len(tagsList), err)
+	}
+	if err != nil {
+		glog.Warningf("Failed to list %s in %s for account %s: %v", tc.name, t.region, t.accountID, err)
+		return nil, err
+	}
+
+	for i := range tagsList {
+		tagsList[i].keys = append([]string{"account_id"}, tagsList[i].keys...)
+		tagsList[i].values = append([]string{t.accountID}, tagsList[i].values...)
+	}
+	t.cache.set(tagsList)
+	return tagsList, nil
+}
+
+// refresh updates the target's cache every interval until ctx is done.
+// The first refresh happens immediately.
+func (tc *TagsCollector) refresh(ctx context.Context, t *target, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// A failed refresh keeps the last good tags in the cache.
+		_, _ = tc.list(ctx, t)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops refreshing the tags in the background, cancelling any refresh in progress.
+// It is called when the collector is replaced or removed.
+func (tc *TagsCollector) Stop() {
+	if tc.stop != nil {
+		tc.stop()
+	}
+}
+
+// Options configures a TagsCollector when it is registered.
+type Options struct {
+	// RefreshInterval is the interval at which the tags are refreshed in the background.
+	// If it is not positive, the tags are listed from AWS on every scrape.
+	RefreshInterval time.Duration
+	// PageLimit is the maximum number of pages fetched by each paginated request, 0 is unlimited.
+	PageLimit int
+	// LabelCollision is how tags whose keys collide with another label are handled.
+	// It defaults to CollisionPrefix.
+	LabelCollision CollisionPolicy
+	// TagPrefix prefixes every tag label name with "tag_" so that tags never collide with default labels.
+	TagPrefix bool
+	// TagFilter decides which tags are converted to labels.
+	TagFilter TagFilter
+	// TagSchema is a fixed set of tag keys that every series is labelled with, using an empty value
+	// when a resource does not have the tag. Other tags are dropped, as are the keys that TagFilter drops.
+	// If it is empty, every series is labelled with the tags of its resource.
+	TagSchema []string
+	// Retry configures how throttled and transiently failing requests are retried.
+	Retry RetryOptions
+	// Timeout bounds how long listing the tags of a single region and account may take, 0 is unbounded.
+	// Scrapes that list the tags on demand are also bounded by the context passed to WithContext.
+	Timeout time.Duration
+	// StaleGracePeriod is how long the tags of the last successful listing are served after listing starts failing.
+	// Once it has expired, the collector serves no tags until listing succeeds again. If it is 0, they are dropped immediately.
+	StaleGracePeriod time.Duration
+	// ResourceTypes are the resource types (e.g. ec2:instance or s3) listed by the resourcegroupstaggingapi collector.
+	// If it is empty, every resource type is listed. Other collectors ignore it.
+	ResourceTypes []string
+	// DisableHealthMetrics stops the collector from setting the aws_tags_collector_* gauges of its targets,
+	// so that collectors built for a single probe do not overwrite those of the registered collectors.
+	DisableHealthMetrics bool
+}
+
+// listing returns the options without those that only configure how tags are converted to labels.
+func (o Options) listing() Options {
+	o.LabelCollision, o.TagPrefix, o.TagFilter, o.TagSchema = "", false, TagFilter{}, nil
+	return o
+}
+
+// SameListing reports whether o and other list the same tags from AWS, that is whether they only differ in how
+// the tags are converted to labels (LabelCollision, TagPrefix, TagFilter and TagSchema).
+// A registered collector whose options change in this way can be replaced by WithLabelOptions.
+func (o Options) SameListing(other Options) bool {
+	return reflect.DeepEqual(o.listing(), other.listing())
+}
+
+// Initialise configures the collector to collect tags in the specified regions and accounts.
+// One lister is created per region and account, unless the resource is region agnostic (e.g. Route53) in which case
+// the tags are only listed once per account.
+// An initialised collector lists the tags on every collection, use Register to refresh them in the background instead.
+func (tc *TagsCollector) Initialise(regions []string, accounts []Account, opts Options) (err error) {
+	if tc.global {
+		regions = []string{"global"}
+	}
+
+	tc.timeout = opts.Timeout
+	tc.gracePeriod = opts.StaleGracePeriod
+	tc.disableHealthMetrics = opts.DisableHealthMetrics
+	err = tc.setLabelOptions(opts)
+	if err != nil {
+		return
+	}
+
+	for _, account := range accounts {
+		for _, region := range regions {
+			r := retryer{RetryOptions: opts.Retry, service: tc.service, region: region}
+			var sess *session.Session
+			if tc.global {
+				sess, err = account.session("", r)
+			} else {
+				sess, err = account.session(region, r)
+			}
+			if err != nil {
+				return
+			}
+
+			lister := tc.newLister()
+			err = lister.Initialise(listerConfig{
+				region:        region,
+				accountID:     account.ID,
+				sess:          sess,
+				maxPages:      opts.PageLimit,
+				resourceTypes: opts.ResourceTypes,
+			})
+			if err != nil {
+				return
+			}
+			tc.targets = append(tc.targets, &target{region: region, accountID: account.ID, lister: lister})
+		}
+	}
+	return
+}
+
+// setLabelOptions configures how the collector converts tags to labels.
+// The tag keys of the schema that the filter drops are removed from it.
+func (tc *TagsCollector) setLabelOptions(opts Options) (err error) {
+	var schema []string
+	for _, key := range opts.TagSchema {
+		if opts.TagFilter.keep(key) {
+			schema = append(schema, key)
+		}
+	}
+	if len(opts.TagSchema) > 0 && len(schema) == 0 {
+		return fmt.Errorf("the tag filter drops every tag key of the schema %v", opts.TagSchema)
+	}
+
+	tc.labelOpts = labelOptions{collision: opts.LabelCollision, prefix: opts.TagPrefix, filter: opts.TagFilter, schema: schema}
+	if tc.labelOpts.collision == "" {
+		tc.labelOpts.collision = CollisionPrefix
+	}
+
+	tc.schemaLabels, err = tc.labelOpts.schemaLabels(tc.labels())
 	if err != nil {
 		return
 	}
+	tc.defaultDesc = tc.desc()
+	return
+}
+
+// WithLabelOptions returns a copy of the initialised collector that converts tags to labels according to opts,
+// but shares the targets, cached tags and background refreshes of tc. Only the options that SameListing ignores are
+// used. The copy must be collected in place of tc, which must then no longer be collected but not stopped.
+func (tc *TagsCollector) WithLabelOptions(opts Options) (*TagsCollector, error) {
+	relabelled := *tc
+	if err := relabelled.setLabelOptions(opts); err != nil {
+		return nil, err
+	}
+	return &relabelled, nil
+}
+
+// Retire deletes the aws_tags_collector_* gauges of the targets of tc that are not collected by next, the collector
+// that replaces it, and stops them from being set again. If next is nil, every target of tc is retired.
+func (tc *TagsCollector) Retire(next *TagsCollector) {
+	collected := make(map[[2]string]bool)
+	if next != nil {
+		for _, t := range next.targets {
+			collected[[2]string{t.region, t.accountID}] = true
+		}
+	}
 
-	for _, tags := range tagsList {
-		tags.sendToPrometheus(ch, tc.name, tc.help)
+	for _, t := range tc.targets {
+		if !collected[[2]string{t.region, t.accountID}] {
+			t.retire(tc.service)
+		}
 	}
 }
 
-// Register registers the collector in the specified prometheus.Registry to collect tags in the specified region.
-// region can be set to anything if the resource is region agnostic (e.g. Route53).
-func (tc *TagsCollector) Register(registry *prometheus.Registry, region string) (err error) {
-	err = tc.lister.Initialise(region)
-	registry.MustRegister(tc)
+// Register initialises the collector to collect tags in the specified regions and accounts, and starts refreshing
+// them in the background if opts has a refresh interval.
+// The collector is collected by the registry of each scrape, see WithContext.
+// Background refreshes run until the collector is stopped.
+func (tc *TagsCollector) Register(regions []string, accounts []Account, opts Options) (err error) {
+	err = tc.Initialise(regions, accounts, opts)
+	if err != nil {
+		return
+	}
+
+	tc.background = opts.RefreshInterval > 0
+	if tc.background {
+		var ctx context.Context
+		ctx, tc.stop = context.WithCancel(context.Background())
+		for _, t := range tc.targets {
+			go tc.refresh(ctx, t, opts.RefreshInterval)
+		}
+	}
 	return
 }
 
 // AvailableCollector maps a string key to each collector (that has been implemented).
 // This is used by the main package to Register the required collectors.
 var AvailableCollectors = map[string]TagsCollector{
-	"autoscaling": autoscalingCollector,
-	"dynamodb":    dynamodbCollector,
-	"ec2":         ec2Collector,
-	"efs":         efsCollector,
-	"elasticache": elasticacheCollector,
-	"elb":         elbCollector,
-	"elbv2":       elbv2Collector,
-	"rds":         rdsCollector,
-	"route53":     route53Collector,
+	"autoscaling":              autoscalingCollector,
+	"dynamodb":                 dynamodbCollector,
+	"ec2":                      ec2Collector,
+	"efs":                      efsCollector,
+	"elasticache":              elasticacheCollector,
+	"elb":                      elbCollector,
+	"elbv2":                    elbv2Collector,
+	"rds":                      rdsCollector,
+	"resourcegroupstaggingapi": resourceGroupsTaggingAPICollector,
+	"route53":                  route53Collector,
 }
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	testRegion    = "eu-west-1"
+	testAccountID = "123456789012"
+)
+
+var (
+	// errFake is returned by fake clients to simulate a failed request
+	errFake = awserr.New("InternalFailure", "fake failure", nil)
+)
+
+// fakeRequest returns a request that is sent without contacting AWS.
+// Sending it fails with err, unless err is nil in which case the output created with it is left as it is.
+func fakeRequest(err error) *request.Request {
+	req := request.New(aws.Config{}, metadata.ClientInfo{}, request.Handlers{}, nil, &request.Operation{Name: "Fake"}, nil, nil)
+	req.Error = err
+	return req
+}
+
+// metricValue returns the value of a counter or gauge.
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	if pb.Counter != nil {
+		return pb.GetCounter().GetValue()
+	}
+	return pb.GetGauge().GetValue()
+}
+
+// listTags initialises the lister in the test region and account, fetching at most maxPages pages, and lists its tags.
+// Each resource is formatted as its comma-separated key=value labels and the resources are sorted,
+// so that the results of listers that build them from maps can be compared.
+func listTags(l tagsLister, maxPages int) ([]string, error) {
+	if err := l.Initialise(listerConfig{region: testRegion, accountID: testAccountID, maxPages: maxPages}); err != nil {
+		return nil, err
+	}
+
+	tagsList, err := l.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []string
+	for _, ts := range tagsList {
+		labels := make([]string, 0, len(ts.keys))
+		for i := range ts.keys {
+			labels = append(labels, ts.keys[i]+"="+ts.values[i])
+		}
+		resources = append(resources, strings.Join(labels, ","))
+	}
+	sort.Strings(resources)
+
+	return resources, nil
+}
+
+// listerTest is a test case for a tagsLister that lists tags with a fake client.
+type listerTest struct {
+	name      string
+	lister    tagsLister
+	resources []string // resources are the expected resources, as formatted by listTags
+	err       bool     // err is true if listing should fail
+	maxPages  int      // maxPages is the maximum number of pages fetched, 0 is unlimited
+	service   string   // service is the service whose pages are counted when pages is positive
+	pages     int      // pages is the number of pages that aws_tags_pages_total should count
+}
+
+func runListerTests(t *testing.T, tests []listerTest) {
+	for _, test := range tests {
+		var counter prometheus.Counter
+		var before float64
+		if test.pages > 0 {
+			counter = PagesTotalMetric.WithLabelValues(test.service, testRegion)
+			before = metricValue(t, counter)
+		}
+
+		resources, err := listTags(test.lister, test.maxPages)
+		if (err != nil) != test.err {
+			t.Errorf("%s: error should be %t, not %v", test.name, test.err, err)
+		}
+		if !reflect.DeepEqual(resources, test.resources) {
+			t.Errorf("%s: resources should be %q, not %q", test.name, test.resources, resources)
+		}
+		if test.pages > 0 {
+			if pages := metricValue(t, counter) - before; pages != float64(test.pages) {
+				t.Errorf("%s: %d pages should be counted, not %f", test.name, test.pages, pages)
+			}
+		}
+	}
+}
+
+func TestInitialiseTargets(t *testing.T) {
+	accounts := []Account{
+		{ID: "123456789012", credentials: credentials.NewStaticCredentials("id", "secret", "")},
+		{ID: "210987654321", credentials: credentials.NewStaticCredentials("id", "secret", "")},
+	}
+	regions := []string{"eu-west-1", "us-east-1", "me-south-1"}
+
+	tests := []struct {
+		collector TagsCollector
+		targets   []string
+	}{
+		{
+			collector: ec2Collector,
+			targets: []string{
+				"123456789012/eu-west-1", "123456789012/us-east-1", "123456789012/me-south-1",
+				"210987654321/eu-west-1", "210987654321/us-east-1", "210987654321/me-south-1",
+			},
+		},
+		// Region agnostic collectors have one target per account.
+		{collector: route53Collector, targets: []string{"123456789012/global", "210987654321/global"}},
+	}
+
+	for _, test := range tests {
+		tc := test.collector
+		if err := tc.Initialise(regions, accounts, Options{}); err != nil {
+			t.Fatalf("%s: %v", tc.service, err)
+		}
+		targets := make([]string, 0, len(tc.targets))
+		for _, target := range tc.targets {
+			targets = append(targets, target.accountID+"/"+target.region)
+		}
+		if !reflect.DeepEqual(targets, test.targets) {
+			t.Errorf("%s: targets should be %v, not %v", tc.service, test.targets, targets)
+		}
+	}
+}
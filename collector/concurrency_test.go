@@ -0,0 +1,258 @@
+package collector
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// blockingLister lists a single resource once it is released, counting how many times it is listed.
+type blockingLister struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (l *blockingLister) Initialise(cfg listerConfig) error {
+	return nil
+}
+
+func (l *blockingLister) List(ctx context.Context) ([]tags, error) {
+	atomic.AddInt32(&l.calls, 1)
+	select {
+	case <-l.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return []tags{{keys: []string{"resource_id", "resource_type", "region", "Name"}, values: []string{"i-1", "instance", testRegion, "web"}}}, nil
+}
+
+// TestConcurrentCollect collects and describes a collector concurrently, as HA Prometheus servers scraping at the
+// same moment do, and checks that they share a single listing. Run it with -race.
+func TestConcurrentCollect(t *testing.T) {
+	lister := &blockingLister{release: make(chan struct{})}
+	tc := ec2Collector
+	tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: lister}}
+	tc.labelOpts = labelOptions{collision: CollisionPrefix}
+	tc.defaultDesc = tc.desc()
+
+	const scrapes = 10
+	var wg sync.WaitGroup
+	wg.Add(2 * scrapes)
+	for i := 0; i < scrapes; i++ {
+		go func() {
+			defer wg.Done()
+			if series := collectFrom(t, &tc); len(series) != 1 {
+				t.Errorf("Collect should emit 1 series, not %d", len(series))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			ch := make(chan *prometheus.Desc, 1)
+			tc.Describe(ch)
+			if desc := <-ch; desc != tc.defaultDesc {
+				t.Errorf("Describe should send %v, not %v", tc.defaultDesc, desc)
+			}
+		}()
+	}
+
+	// Give every collection time to join the listing before it completes
+	time.Sleep(100 * time.Millisecond)
+	close(lister.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&lister.calls); calls != 1 {
+		t.Errorf("Concurrent collections should share 1 listing, not %d", calls)
+	}
+}
+
+// TestListSharedCancel checks that a collection waiting for a shared listing stops waiting when its own context is done.
+func TestListSharedCancel(t *testing.T) {
+	lister := &blockingLister{release: make(chan struct{})}
+	tc := ec2Collector
+	tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: lister}}
+
+	done := make(chan error)
+	go func() {
+		_, err := tc.list(context.Background(), tc.targets[0])
+		done <- err
+	}()
+	for atomic.LoadInt32(&lister.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tc.list(ctx, tc.targets[0]); err != context.Canceled {
+		t.Errorf("Waiting for a shared listing should stop with %v, not %v", context.Canceled, err)
+	}
+
+	close(lister.release)
+	if err := <-done; err != nil {
+		t.Errorf("The shared listing should succeed, not %v", err)
+	}
+	if calls := atomic.LoadInt32(&lister.calls); calls != 1 {
+		t.Errorf("The collections should share 1 listing, not %d", calls)
+	}
+}
+
+// TestListSharedStarterCancel checks that a shared listing outlives the collection that started it, so that the other
+// collections waiting for it get the tags rather than its cancellation.
+func TestListSharedStarterCancel(t *testing.T) {
+	lister := &blockingLister{release: make(chan struct{})}
+	tc := ec2Collector
+	tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: lister}}
+	target := tc.targets[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan error)
+	go func() {
+		_, err := tc.list(ctx, target)
+		started <- err
+	}()
+	for atomic.LoadInt32(&lister.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	joined := make(chan error)
+	go func() {
+		tagsList, err := tc.list(context.Background(), target)
+		if err == nil && len(tagsList) != 1 {
+			t.Errorf("The shared listing should return 1 resource, not %d", len(tagsList))
+		}
+		joined <- err
+	}()
+	for waiters := 0; waiters < 2; {
+		time.Sleep(time.Millisecond)
+		target.mu.Lock()
+		waiters = target.listing.waiters
+		target.mu.Unlock()
+	}
+
+	cancel()
+	if err := <-started; err != context.Canceled {
+		t.Errorf("The collection that started the listing should stop with %v, not %v", context.Canceled, err)
+	}
+	close(lister.release)
+	if err := <-joined; err != nil {
+		t.Errorf("The shared listing should succeed once the collection that started it is cancelled, not %v", err)
+	}
+	if s, _ := target.status(); s.LastError != "" || s.LastSuccess == nil {
+		t.Errorf("The shared listing should be recorded as a success, not %+v", s)
+	}
+}
+
+// TestListAbandonedNotRecorded checks that a listing abandoned with its context is not recorded as a failure of the target.
+func TestListAbandonedNotRecorded(t *testing.T) {
+	const region = "abandoned-test-1"
+	lister := &blockingLister{release: make(chan struct{})}
+	tc := ec2Collector
+	tc.timeout = time.Hour
+	tc.targets = []*target{{region: region, accountID: testAccountID, lister: lister}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := tc.listOnce(ctx, tc.targets[0])
+		done <- err
+	}()
+	for atomic.LoadInt32(&lister.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("The abandoned listing should stop with %v, not %v", context.Canceled, err)
+	}
+	if s, _ := tc.targets[0].status(); s.LastError != "" {
+		t.Errorf("The abandoned listing should not be recorded as an error, not %q", s.LastError)
+	}
+	labels := prometheus.Labels{"collector": tc.service, "region": region, "account_id": testAccountID}
+	if CollectorSuccessMetric.Delete(labels) {
+		t.Error("The abandoned listing should not set the health metrics")
+	}
+}
+
+// TestListSharedTimeout checks that a shared listing is bound by the collector's timeout.
+func TestListSharedTimeout(t *testing.T) {
+	lister := &blockingLister{release: make(chan struct{})}
+	tc := ec2Collector
+	tc.timeout = 10 * time.Millisecond
+	tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: lister}}
+
+	if _, err := tc.list(context.Background(), tc.targets[0]); err != context.DeadlineExceeded {
+		t.Errorf("The shared listing should stop with %v, not %v", context.DeadlineExceeded, err)
+	}
+	if s, _ := tc.targets[0].status(); s.LastError == "" {
+		t.Error("A listing that times out should be recorded as an error")
+	}
+}
+
+// TestListerCancelledWithScrape checks that the requests of a lister that are in flight when the only scrape waiting for
+// them ends are cancelled.
+func TestListerCancelledWithScrape(t *testing.T) {
+	received, cancelled := make(chan struct{}, 10), make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices that the client went away once it has read the request
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+		received <- struct{}{}
+		<-r.Context().Done()
+		cancelled <- struct{}{}
+	}))
+	defer srv.Close()
+
+	a := Account{ID: testAccountID, credentials: credentials.NewStaticCredentials("id", "secret", "")}
+	sess, err := a.session(testRegion, retryer{service: "ec2", region: testRegion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Config.Endpoint = aws.String(srv.URL)
+
+	tc := ec2Collector
+	tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: newEC2Lister(nil)}}
+	if err := tc.targets[0].lister.Initialise(listerConfig{region: testRegion, accountID: testAccountID, sess: sess}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.setLabelOptions(Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan prometheus.Metric, 1)
+	done := make(chan struct{})
+	go func() {
+		tc.WithContext(ctx).Collect(ch)
+		close(done)
+	}()
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The lister should send a request")
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The collection should end with the scrape")
+	}
+	if len(ch) != 0 {
+		t.Errorf("The collection should not emit any series, not %d", len(ch))
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("The request in flight should be cancelled with the scrape")
+	}
+}
@@ -0,0 +1,233 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// conformanceResources are the names of the resources listed by every conformance lister
+	conformanceResources = []string{"alpha", "bravo", "charlie"}
+	// conformanceTags are the tags of each of the conformanceResources, which differ so that the series have different tags
+	conformanceTags = map[string]map[string]string{
+		"alpha":   {"Name": "conformance", "team": "infra"},
+		"bravo":   {"Name": "conformance", "cost-centre": "42"},
+		"charlie": {"team": "data", "cost-centre": "7", "env": "prod"},
+	}
+)
+
+// conformanceListers create a lister for each collector that lists the conformanceTags of each of the named resources.
+// Every collector in AvailableCollectors must have one.
+var conformanceListers = map[string]func(names []string) tagsLister{
+	"autoscaling": func(names []string) tagsLister {
+		var page []*autoscaling.TagDescription
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				page = append(page, autoscalingTag(name, k, v))
+			}
+		}
+		return newAutoScalingLister(&fakeAutoScaling{pages: [][]*autoscaling.TagDescription{page}})
+	},
+	"dynamodb": func(names []string) tagsLister {
+		tags := make(map[string][]*dynamodb.Tag)
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				tags[name] = append(tags[name], &dynamodb.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+		}
+		return newDynamoDBLister(&fakeDynamoDB{pages: [][]string{names}, tags: tags})
+	},
+	"ec2": func(names []string) tagsLister {
+		var page []*ec2.TagDescription
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				page = append(page, ec2Tag(name, "instance", k, v))
+			}
+		}
+		return newEC2Lister(&fakeEC2{pages: [][]*ec2.TagDescription{page}})
+	},
+	"efs": func(names []string) tagsLister {
+		tags := make(map[string][]*efs.Tag)
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				tags[name] = append(tags[name], &efs.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+		}
+		return newEFSLister(&fakeEFS{pages: [][]string{names}, tags: tags})
+	},
+	"elasticache": func(names []string) tagsLister {
+		tags := make(map[string][]*elasticache.Tag)
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				tags[elasticacheARN(name)] = append(tags[elasticacheARN(name)], &elasticache.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+		}
+		return newElasticacheLister(&fakeElasticache{pages: [][]string{names}, tags: tags})
+	},
+	"elb": func(names []string) tagsLister {
+		tags := make(map[string][]*elb.Tag)
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				tags[name] = append(tags[name], &elb.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+		}
+		return newELBLister(&fakeELB{pages: [][]string{names}, tags: tags})
+	},
+	"elbv2": func(names []string) tagsLister {
+		tags := make(map[string][]*elbv2.Tag)
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				tags[name] = append(tags[name], &elbv2.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+		}
+		return newELBV2Lister(&fakeELBV2{pages: [][]string{names}, tags: tags})
+	},
+	"rds": func(names []string) tagsLister {
+		tags := make(map[string][]*rds.Tag)
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				tags[name] = append(tags[name], &rds.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+		}
+		return newRDSLister(&fakeRDS{pages: [][]string{names}, tags: tags})
+	},
+	"resourcegroupstaggingapi": func(names []string) tagsLister {
+		var page []*resourcegroupstaggingapi.ResourceTagMapping
+		for _, name := range names {
+			mapping := resourceTagMapping("arn:aws:ec2:eu-west-1:123456789012:instance/" + name)
+			for k, v := range conformanceTags[name] {
+				mapping.Tags = append(mapping.Tags, &resourcegroupstaggingapi.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+			page = append(page, mapping)
+		}
+		return newResourceGroupsTaggingAPILister(&fakeResourceGroupsTaggingAPI{pages: [][]*resourcegroupstaggingapi.ResourceTagMapping{page}})
+	},
+	"route53": func(names []string) tagsLister {
+		tags := make(map[string][]*route53.Tag)
+		for _, name := range names {
+			for k, v := range conformanceTags[name] {
+				tags[name] = append(tags[name], &route53.Tag{Key: aws.String(k), Value: aws.String(v)})
+			}
+		}
+		return newRoute53Lister(&fakeRoute53{zones: [][]string{names}, tags: tags})
+	},
+}
+
+// collectFrom gathers the metrics of the collector through a registry and returns the labels of each of them.
+func collectFrom(t *testing.T, tc *TagsCollector) []map[string]string {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(tc); err != nil {
+		t.Fatalf("%s: failed to register: %v", tc.service, err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Errorf("%s: failed to gather: %v", tc.service, err)
+	}
+
+	var series []map[string]string
+	for _, family := range families {
+		for _, m := range family.Metric {
+			labels := make(map[string]string, len(m.Label))
+			for _, l := range m.Label {
+				labels[l.GetName()] = l.GetValue()
+			}
+			series = append(series, labels)
+		}
+	}
+
+	return series
+}
+
+// TestCollectorConformance runs every collector against fake AWS responses and gathers it through a registry,
+// checking that it emits one series per resource, labelled with the default labels and every tag of the resource.
+func TestCollectorConformance(t *testing.T) {
+	for name, collector := range AvailableCollectors {
+		newLister, ok := conformanceListers[name]
+		if !ok {
+			t.Errorf("%s: no conformance lister", name)
+			continue
+		}
+
+		lister := newLister(conformanceResources)
+		if err := lister.Initialise(listerConfig{region: testRegion, accountID: testAccountID}); err != nil {
+			t.Errorf("%s: failed to initialise: %v", name, err)
+			continue
+		}
+		tc := collector
+		tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: lister}}
+		tc.labelOpts = labelOptions{collision: CollisionPrefix}
+
+		series := collectFrom(t, &tc)
+		if len(series) != len(conformanceResources) {
+			t.Errorf("%s: should emit %d series, not %d", name, len(conformanceResources), len(series))
+		}
+
+		resources := make(map[string]int, len(conformanceResources))
+		seen := make(map[string]struct{}, len(series))
+		for _, labels := range series {
+			for _, l := range tc.labels() {
+				if labels[l] == "" {
+					t.Errorf("%s: default label %s is missing from %v", name, l, labels)
+				}
+			}
+			if labels["account_id"] != testAccountID {
+				t.Errorf("%s: account_id should be %s, not %s", name, testAccountID, labels["account_id"])
+			}
+
+			if resource := conformanceResource(labels); resource == "" {
+				t.Errorf("%s: series %v should have the tags of one resource and empty labels for the others", name, labels)
+			} else {
+				resources[resource]++
+			}
+
+			pairs := make([]string, 0, len(labels))
+			for k, v := range labels {
+				pairs = append(pairs, k+"="+v)
+			}
+			sort.Strings(pairs)
+			key := strings.Join(pairs, ",")
+			if _, ok := seen[key]; ok {
+				t.Errorf("%s: duplicate series %s", name, key)
+			}
+			seen[key] = struct{}{}
+		}
+		for _, resource := range conformanceResources {
+			if resources[resource] != 1 {
+				t.Errorf("%s: resource %s should have 1 series, not %d", name, resource, resources[resource])
+			}
+		}
+	}
+}
+
+// conformanceResource returns the conformance resource whose tags the labels have, or an empty string if there is none.
+// The labels of the tags that the resource does not have must be empty.
+func conformanceResource(labels map[string]string) string {
+	for resource, tags := range conformanceTags {
+		match := true
+		for _, other := range conformanceTags {
+			for k := range other {
+				if labels[sanitizeLabelName(k)] != tags[k] {
+					match = false
+				}
+			}
+		}
+		if match {
+			return resource
+		}
+	}
+	return ""
+}
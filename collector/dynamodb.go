@@ -1,10 +1,11 @@
 package collector
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -22,72 +23,82 @@ var (
 )
 
 var dynamodbCollector = TagsCollector{
+	service:       "dynamodb",
 	name:          prometheus.BuildFQName(namespace, "dynamodb", "tags"),
 	help:          "AWS DynamoDB tags converted to Prometheus labels.",
 	defaultLabels: []string{"name", "identifier", "region"},
-	lister:        &dynamodbLister{},
+	newLister:     func() tagsLister { return newDynamoDBLister(nil) },
 }
 
 type dynamodbLister struct {
-	region  string
-	session *dynamodb.DynamoDB
+	listerConfig
+	session dynamodbiface.DynamoDBAPI
 }
 
-func (db *dynamodbLister) Initialise(region string) (err error) {
-	db.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &db.region})
-	if err != nil {
-		return
+// newDynamoDBLister creates a dynamodbLister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newDynamoDBLister(client dynamodbiface.DynamoDBAPI) *dynamodbLister {
+	return &dynamodbLister{session: client}
+}
+
+func (db *dynamodbLister) Initialise(cfg listerConfig) error {
+	db.listerConfig = cfg
+	if db.session == nil {
+		db.session = dynamodb.New(cfg.sess)
 	}
-	db.session = dynamodb.New(sess)
-	return
+	return nil
 }
 
-func (db *dynamodbLister) List() ([]tags, error) {
+func (db *dynamodbLister) List(ctx context.Context) ([]tags, error) {
 	listDBInput := &dynamodb.ListTablesInput{Limit: &dynamodbMaxRecords}
-	tableList, err := db.session.ListTables(listDBInput)
-	RequestTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
+	tableNames := make([]*string, 0)
+	pages := 0
+	err := db.session.ListTablesPagesWithContext(ctx, listDBInput, func(tableList *dynamodb.ListTablesOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
+		tableNames = append(tableNames, tableList.TableNames...)
+		return db.nextPage("dynamodb", &pages, lastPage)
+	})
+
 	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
 		RequestErrorTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
 		return []tags{}, err
 	}
 
-	descReqs := make([]*request.Request, 0, len(tableList.TableNames))
-	descOuts := make([]*dynamodb.DescribeTableOutput, 0, len(tableList.TableNames))
-	for i := range tableList.TableNames {
-		descReqsIn := &dynamodb.DescribeTableInput{TableName: tableList.TableNames[i]}
+	descReqs := make([]*request.Request, 0, len(tableNames))
+	descOuts := make([]*dynamodb.DescribeTableOutput, 0, len(tableNames))
+	for i := range tableNames {
+		descReqsIn := &dynamodb.DescribeTableInput{TableName: tableNames[i]}
 		req, out := db.session.DescribeTableRequest(descReqsIn)
 		descReqs = append(descReqs, req)
 		descOuts = append(descOuts, out)
 	}
 
-	errs := makeConcurrentRequests(descReqs, "dynamodb")
-	tagsReqs := make([]*request.Request, 0, len(tableList.TableNames))
-	tagsOuts := make([]*dynamodb.ListTagsOfResourceOutput, 0, len(tableList.TableNames))
+	errs := makeConcurrentRequests(ctx, descReqs, "dynamodb")
+	tables := make([]*dynamodb.TableDescription, 0, len(tableNames))
+	tagsReqs := make([]*request.Request, 0, len(tableNames))
+	tagsOuts := make([]*dynamodb.ListTagsOfResourceOutput, 0, len(tableNames))
 	for i := range descOuts {
 		RequestTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
 		if errs[i] != nil {
 			RequestErrorTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
-			// Required for indexing logic
-			tagsReqs = append(tagsReqs, &request.Request{})
-			tagsOuts = append(tagsOuts, &dynamodb.ListTagsOfResourceOutput{})
 			continue
 		}
 
 		tagsIn := &dynamodb.ListTagsOfResourceInput{ResourceArn: descOuts[i].Table.TableArn}
 		req, out := db.session.ListTagsOfResourceRequest(tagsIn)
+		tables = append(tables, descOuts[i].Table)
 		tagsReqs = append(tagsReqs, req)
 		tagsOuts = append(tagsOuts, out)
 	}
 
-	errs = makeConcurrentRequests(tagsReqs, "dynamodb")
+	errs = makeConcurrentRequests(ctx, tagsReqs, "dynamodb")
 
-	tagsList := make([]tags, 0, len(tableList.TableNames))
+	tagsList := make([]tags, 0, len(tableNames))
 	for i := range tagsOuts {
 		RequestTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
 		if errs[i] != nil {
 			RequestErrorTotalMetric.With(prometheus.Labels{"service": "dynamodb", "region": db.region}).Inc()
-			// Don't need to maintain size anymore
 			continue
 		}
 
@@ -97,7 +108,7 @@ func (db *dynamodbLister) List() ([]tags, error) {
 		}
 
 		ts.keys = append(ts.keys, dynamodbCollector.defaultLabels...)
-		ts.values = append(ts.values, *descOuts[i].Table.TableName, *descOuts[i].Table.TableId, db.region)
+		ts.values = append(ts.values, *tables[i].TableName, *tables[i].TableId, db.region)
 
 		for _, t := range tagsOuts[i].Tags {
 			ts.keys = append(ts.keys, *t.Key)
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	fakeDynamoDBARNPrefix = "arn:aws:dynamodb:eu-west-1:123456789012:table/"
+)
+
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	pages   [][]string                 // pages are the table names returned by each page of ListTables
+	tags    map[string][]*dynamodb.Tag // tags are the tags of each table
+	failing map[string]bool            // failing are the tables whose DescribeTable request fails
+	listErr error
+}
+
+func (f *fakeDynamoDB) ListTablesPagesWithContext(ctx aws.Context, in *dynamodb.ListTablesInput, fn func(*dynamodb.ListTablesOutput, bool) bool, opts ...request.Option) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
+	for i, page := range f.pages {
+		if !fn(&dynamodb.ListTablesOutput{TableNames: aws.StringSlice(page)}, i == len(f.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeDynamoDB) DescribeTableRequest(in *dynamodb.DescribeTableInput) (*request.Request, *dynamodb.DescribeTableOutput) {
+	name := *in.TableName
+	if f.failing[name] {
+		return fakeRequest(errFake), &dynamodb.DescribeTableOutput{}
+	}
+	return fakeRequest(nil), &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+		TableName: aws.String(name),
+		TableId:   aws.String(name + "-id"),
+		TableArn:  aws.String(fakeDynamoDBARNPrefix + name),
+	}}
+}
+
+func (f *fakeDynamoDB) ListTagsOfResourceRequest(in *dynamodb.ListTagsOfResourceInput) (*request.Request, *dynamodb.ListTagsOfResourceOutput) {
+	name := strings.TrimPrefix(*in.ResourceArn, fakeDynamoDBARNPrefix)
+	return fakeRequest(nil), &dynamodb.ListTagsOfResourceOutput{Tags: f.tags[name]}
+}
+
+func TestDynamoDBLister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name: "tables across pages",
+			lister: newDynamoDBLister(&fakeDynamoDB{
+				pages: [][]string{{"users"}, {"orders"}},
+				tags: map[string][]*dynamodb.Tag{
+					"users":  {{Key: aws.String("team"), Value: aws.String("identity")}},
+					"orders": {{Key: aws.String("team"), Value: aws.String("payments")}},
+				},
+			}),
+			resources: []string{
+				"name=orders,identifier=orders-id,region=eu-west-1,team=payments",
+				"name=users,identifier=users-id,region=eu-west-1,team=identity",
+			},
+		},
+		{
+			name: "failed describe skips the table",
+			lister: newDynamoDBLister(&fakeDynamoDB{
+				pages:   [][]string{{"users", "orders"}},
+				tags:    map[string][]*dynamodb.Tag{"orders": {{Key: aws.String("team"), Value: aws.String("payments")}}},
+				failing: map[string]bool{"users": true},
+			}),
+			resources: []string{"name=orders,identifier=orders-id,region=eu-west-1,team=payments"},
+		},
+		{
+			name:   "failed list",
+			lister: newDynamoDBLister(&fakeDynamoDB{listErr: errFake}),
+			err:    true,
+		},
+	})
+}
@@ -1,9 +1,10 @@
 package collector
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"context"
+
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -12,56 +13,72 @@ var (
 )
 
 var ec2Collector = TagsCollector{
+	service:       "ec2",
 	name:          prometheus.BuildFQName(namespace, "ec2", "tags"),
 	help:          "AWS EC2 tags converted to Prometheus labels.",
 	defaultLabels: []string{"resource_id", "resource_type", "region"},
-	lister:        &ec2Lister{},
+	newLister:     func() tagsLister { return newEC2Lister(nil) },
 }
 
 type ec2Lister struct {
-	region  string
-	session *ec2.EC2
+	listerConfig
+	session ec2iface.EC2API
 }
 
-func (ec *ec2Lister) Initialise(region string) (err error) {
-	ec.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &ec.region})
-	if err != nil {
-		return
-	}
-	ec.session = ec2.New(sess)
-	return
+// newEC2Lister creates an ec2Lister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newEC2Lister(client ec2iface.EC2API) *ec2Lister {
+	return &ec2Lister{session: client}
 }
 
-func (ec *ec2Lister) List() ([]tags, error) {
-	res, err := ec.session.DescribeTags(&ec2.DescribeTagsInput{MaxResults: &ec2MaxRecords})
-
-	RequestTotalMetric.With(prometheus.Labels{"service": "ec2", "region": ec.region}).Inc()
-	if err != nil {
-		RequestErrorTotalMetric.With(prometheus.Labels{"service": "ec2", "region": ec.region}).Inc()
-		return []tags{}, err
+func (ec *ec2Lister) Initialise(cfg listerConfig) error {
+	ec.listerConfig = cfg
+	if ec.session == nil {
+		ec.session = ec2.New(cfg.sess)
 	}
+	return nil
+}
 
+func (ec *ec2Lister) List(ctx context.Context) ([]tags, error) {
 	tagMap := make(map[string]tags, 0)
 	typeMap := make(map[string]*string, 0)
-	for _, tagDesc := range res.Tags {
-		ts, ok := tagMap[*tagDesc.ResourceId]
-		if !ok {
-			ts = tags{make([]string, 0), make([]string, 0)}
+	pages := 0
+	err := ec.session.DescribeTagsPagesWithContext(ctx, &ec2.DescribeTagsInput{MaxResults: &ec2MaxRecords}, func(res *ec2.DescribeTagsOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "ec2", "region": ec.region}).Inc()
+		for _, tagDesc := range res.Tags {
+			ts, ok := tagMap[*tagDesc.ResourceId]
+			if !ok {
+				ts = tags{make([]string, 0), make([]string, 0)}
+			}
+
+			ts.keys = append(ts.keys, *tagDesc.Key)
+			ts.values = append(ts.values, *tagDesc.Value)
+			tagMap[*tagDesc.ResourceId] = ts
+			typeMap[*tagDesc.ResourceId] = tagDesc.ResourceType
 		}
 
-		ts.keys = append(ts.keys, *tagDesc.Key)
-		ts.values = append(ts.values, *tagDesc.Value)
-		tagMap[*tagDesc.ResourceId] = ts
-		typeMap[*tagDesc.ResourceId] = tagDesc.ResourceType
+		return ec.nextPage("ec2", &pages, lastPage)
+	})
+
+	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "ec2", "region": ec.region}).Inc()
+		RequestErrorTotalMetric.With(prometheus.Labels{"service": "ec2", "region": ec.region}).Inc()
+		return []tags{}, err
 	}
 
 	tagsList := make([]tags, 0, len(tagMap))
 	for k, v := range tagMap {
-		v.keys = append(v.keys, ec2Collector.defaultLabels...)
-		v.values = append(v.values, k, *typeMap[k], ec.region)
+		ts := tags{
+			make([]string, 0, len(v.keys)+len(ec2Collector.defaultLabels)),
+			make([]string, 0, len(v.keys)+len(ec2Collector.defaultLabels)),
+		}
+
+		ts.keys = append(ts.keys, ec2Collector.defaultLabels...)
+		ts.values = append(ts.values, k, *typeMap[k], ec.region)
+		ts.keys = append(ts.keys, v.keys...)
+		ts.values = append(ts.values, v.values...)
 
-		tagsList = append(tagsList, v)
+		tagsList = append(tagsList, ts)
 	}
 	return tagsList, nil
 }
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+type fakeEC2 struct {
+	ec2iface.EC2API
+	pages [][]*ec2.TagDescription
+	err   error
+}
+
+func (f *fakeEC2) DescribeTagsPagesWithContext(ctx aws.Context, in *ec2.DescribeTagsInput, fn func(*ec2.DescribeTagsOutput, bool) bool, opts ...request.Option) error {
+	if f.err != nil {
+		return f.err
+	}
+	for i, page := range f.pages {
+		if !fn(&ec2.DescribeTagsOutput{Tags: page}, i == len(f.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func ec2Tag(id, resourceType, key, value string) *ec2.TagDescription {
+	return &ec2.TagDescription{ResourceId: aws.String(id), ResourceType: aws.String(resourceType), Key: aws.String(key), Value: aws.String(value)}
+}
+
+func TestEC2Lister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name: "resources across pages",
+			lister: newEC2Lister(&fakeEC2{pages: [][]*ec2.TagDescription{
+				{ec2Tag("i-1", "instance", "Name", "web"), ec2Tag("i-1", "instance", "team", "infra")},
+				{ec2Tag("vol-1", "volume", "Name", "data")},
+			}}),
+			resources: []string{
+				"resource_id=i-1,resource_type=instance,region=eu-west-1,Name=web,team=infra",
+				"resource_id=vol-1,resource_type=volume,region=eu-west-1,Name=data",
+			},
+			service: "ec2",
+			pages:   2,
+		},
+		{
+			name: "page limit",
+			lister: newEC2Lister(&fakeEC2{pages: [][]*ec2.TagDescription{
+				{ec2Tag("i-1", "instance", "Name", "web")},
+				{ec2Tag("vol-1", "volume", "Name", "data")},
+			}}),
+			resources: []string{"resource_id=i-1,resource_type=instance,region=eu-west-1,Name=web"},
+			maxPages:  1,
+			service:   "ec2",
+			pages:     1,
+		},
+		{
+			name:   "no tags",
+			lister: newEC2Lister(&fakeEC2{}),
+		},
+		{
+			name:   "failed request",
+			lister: newEC2Lister(&fakeEC2{err: errFake}),
+			err:    true,
+		},
+	})
+}
@@ -1,56 +1,73 @@
 package collector
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/efs/efsiface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var efsCollector = TagsCollector{
+	service:       "efs",
 	name:          prometheus.BuildFQName(namespace, "efs", "tags"),
 	help:          "AWS EFS tags converted to Prometheus labels.",
 	defaultLabels: []string{"file_system_name", "region"},
-	lister:        &efsLister{},
+	newLister:     func() tagsLister { return newEFSLister(nil) },
 }
 
 type efsLister struct {
-	region  string
-	session *efs.EFS
+	listerConfig
+	session efsiface.EFSAPI
+}
+
+// newEFSLister creates an efsLister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newEFSLister(client efsiface.EFSAPI) *efsLister {
+	return &efsLister{session: client}
 }
 
-func (ef *efsLister) Initialise(region string) (err error) {
-	ef.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &ef.region})
-	if err != nil {
-		return
+func (ef *efsLister) Initialise(cfg listerConfig) error {
+	ef.listerConfig = cfg
+	if ef.session == nil {
+		ef.session = efs.New(cfg.sess)
 	}
-	ef.session = efs.New(sess)
-	return
+	return nil
 }
 
-func (ef *efsLister) List() ([]tags, error) {
+func (ef *efsLister) List(ctx context.Context) ([]tags, error) {
 
 	dfsInput := &efs.DescribeFileSystemsInput{}
-	fsOut, err := ef.session.DescribeFileSystems(dfsInput)
-	RequestTotalMetric.With(prometheus.Labels{"service": "autoscaling", "region": ef.region}).Inc()
-	if err != nil {
-		RequestErrorTotalMetric.With(prometheus.Labels{"service": "autoscaling", "region": ef.region}).Inc()
-		return []tags{}, err
+	fileSystems := make([]*efs.FileSystemDescription, 0)
+	pages := 0
+	for {
+		fsOut, err := ef.session.DescribeFileSystemsWithContext(ctx, dfsInput)
+		RequestTotalMetric.With(prometheus.Labels{"service": "efs", "region": ef.region}).Inc()
+		if err != nil {
+			RequestErrorTotalMetric.With(prometheus.Labels{"service": "efs", "region": ef.region}).Inc()
+			return []tags{}, err
+		}
+
+		fileSystems = append(fileSystems, fsOut.FileSystems...)
+		if !ef.nextPage("efs", &pages, fsOut.NextMarker == nil) {
+			break
+		}
+		dfsInput.Marker = fsOut.NextMarker
 	}
 
-	reqs := make([]*request.Request, 0, len(fsOut.FileSystems))
-	outs := make([]*efs.DescribeTagsOutput, 0, len(fsOut.FileSystems))
-	for _, fs := range fsOut.FileSystems {
+	reqs := make([]*request.Request, 0, len(fileSystems))
+	outs := make([]*efs.DescribeTagsOutput, 0, len(fileSystems))
+	for _, fs := range fileSystems {
 		in := &efs.DescribeTagsInput{FileSystemId: fs.FileSystemId}
 		req, out := ef.session.DescribeTagsRequest(in)
 		reqs = append(reqs, req)
 		outs = append(outs, out)
 	}
 
-	errs := makeConcurrentRequests(reqs, "efs")
-	tagsList := make([]tags, 0, len(fsOut.FileSystems))
+	errs := makeConcurrentRequests(ctx, reqs, "efs")
+	tagsList := make([]tags, 0, len(fileSystems))
 	for i := range outs {
 		RequestTotalMetric.With(prometheus.Labels{"service": "efs", "region": ef.region}).Inc()
 		if errs[i] != nil {
@@ -64,7 +81,7 @@ func (ef *efsLister) List() ([]tags, error) {
 		}
 
 		ts.keys = append(ts.keys, efsCollector.defaultLabels...)
-		ts.values = append(ts.values, *fsOut.FileSystems[i].Name, ef.region)
+		ts.values = append(ts.values, aws.StringValue(fileSystems[i].Name), ef.region)
 
 		for _, t := range outs[i].Tags {
 			ts.keys = append(ts.keys, *t.Key)
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/efs"
+	"github.com/aws/aws-sdk-go/service/efs/efsiface"
+)
+
+type fakeEFS struct {
+	efsiface.EFSAPI
+	pages   [][]string            // pages are the file system names returned by each page of DescribeFileSystems
+	tags    map[string][]*efs.Tag // tags are the tags of each file system
+	failing map[string]bool       // failing are the file systems whose DescribeTags request fails
+	unnamed map[string]bool       // unnamed are the file systems without a Name tag
+	listErr error
+}
+
+func (f *fakeEFS) DescribeFileSystemsWithContext(ctx aws.Context, in *efs.DescribeFileSystemsInput, opts ...request.Option) (*efs.DescribeFileSystemsOutput, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	if len(f.pages) == 0 {
+		return &efs.DescribeFileSystemsOutput{}, nil
+	}
+
+	// The marker is the index of the page
+	page, _ := strconv.Atoi(aws.StringValue(in.Marker))
+
+	out := &efs.DescribeFileSystemsOutput{}
+	for _, name := range f.pages[page] {
+		fs := &efs.FileSystemDescription{FileSystemId: aws.String(name), Name: aws.String(name)}
+		if f.unnamed[name] {
+			fs.Name = nil
+		}
+		out.FileSystems = append(out.FileSystems, fs)
+	}
+	if page+1 < len(f.pages) {
+		out.NextMarker = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func (f *fakeEFS) DescribeTagsRequest(in *efs.DescribeTagsInput) (*request.Request, *efs.DescribeTagsOutput) {
+	if f.failing[*in.FileSystemId] {
+		return fakeRequest(errFake), &efs.DescribeTagsOutput{}
+	}
+	return fakeRequest(nil), &efs.DescribeTagsOutput{Tags: f.tags[*in.FileSystemId]}
+}
+
+func TestEFSLister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name: "file systems across pages",
+			lister: newEFSLister(&fakeEFS{
+				pages: [][]string{{"home"}, {"shared"}},
+				tags: map[string][]*efs.Tag{
+					"home":   {{Key: aws.String("team"), Value: aws.String("infra")}},
+					"shared": {{Key: aws.String("team"), Value: aws.String("data")}},
+				},
+			}),
+			resources: []string{
+				"file_system_name=home,region=eu-west-1,team=infra",
+				"file_system_name=shared,region=eu-west-1,team=data",
+			},
+		},
+		{
+			name: "failed tags request skips the file system",
+			lister: newEFSLister(&fakeEFS{
+				pages:   [][]string{{"home", "shared"}},
+				failing: map[string]bool{"home": true},
+			}),
+			resources: []string{"file_system_name=shared,region=eu-west-1"},
+		},
+		{
+			name: "file system without a name",
+			lister: newEFSLister(&fakeEFS{
+				pages:   [][]string{{"home"}},
+				unnamed: map[string]bool{"home": true},
+			}),
+			resources: []string{"file_system_name=,region=eu-west-1"},
+		},
+		{
+			name:   "failed list",
+			lister: newEFSLister(&fakeEFS{listErr: errFake}),
+			err:    true,
+		},
+	})
+}
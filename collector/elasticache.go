@@ -1,13 +1,14 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elasticache/elasticacheiface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -24,27 +25,30 @@ var (
 )
 
 var elasticacheCollector = TagsCollector{
+	service:       "elasticache",
 	name:          prometheus.BuildFQName(namespace, "elasticache", "tags"),
 	help:          "AWS Elasticache tags converted to Prometheus labels.",
 	defaultLabels: []string{"name", "resource_type", "region"},
-	lister:        &elasticacheLister{},
+	newLister:     func() tagsLister { return newElasticacheLister(nil) },
 }
 
 type elasticacheLister struct {
-	region    string
-	accountID string
-	session   *elasticache.ElastiCache
+	listerConfig
+	session elasticacheiface.ElastiCacheAPI
 }
 
-func (el *elasticacheLister) Initialise(region string) (err error) {
-	el.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &el.region})
-	if err != nil {
-		return
+// newElasticacheLister creates an elasticacheLister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newElasticacheLister(client elasticacheiface.ElastiCacheAPI) *elasticacheLister {
+	return &elasticacheLister{session: client}
+}
+
+func (el *elasticacheLister) Initialise(cfg listerConfig) error {
+	el.listerConfig = cfg
+	if el.session == nil {
+		el.session = elasticache.New(cfg.sess)
 	}
-	el.session = elasticache.New(sess)
-	el.accountID, err = getAccountID()
-	return
+	return nil
 }
 
 func (el *elasticacheLister) generateARN(resourceName *string) *string {
@@ -57,18 +61,25 @@ func (el *elasticacheLister) generateARN(resourceName *string) *string {
 	}.String())
 }
 
-func (el *elasticacheLister) List() ([]tags, error) {
-	clusters, err := el.session.DescribeCacheClusters(&elasticache.DescribeCacheClustersInput{})
-	RequestTotalMetric.With(prometheus.Labels{"service": "elasticache", "region": el.region}).Inc()
+func (el *elasticacheLister) List(ctx context.Context) ([]tags, error) {
+	clusters := make([]*elasticache.CacheCluster, 0)
+	pages := 0
+	err := el.session.DescribeCacheClustersPagesWithContext(ctx, &elasticache.DescribeCacheClustersInput{}, func(out *elasticache.DescribeCacheClustersOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "elasticache", "region": el.region}).Inc()
+		clusters = append(clusters, out.CacheClusters...)
+		return el.nextPage("elasticache", &pages, lastPage)
+	})
+
 	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "elasticache", "region": el.region}).Inc()
 		RequestErrorTotalMetric.With(prometheus.Labels{"service": "elasticache", "region": el.region}).Inc()
 		return []tags{}, err
 	}
 
-	reqs := make([]*request.Request, 0, len(clusters.CacheClusters))
-	outs := make([]*elasticache.TagListMessage, 0, len(clusters.CacheClusters))
+	reqs := make([]*request.Request, 0, len(clusters))
+	outs := make([]*elasticache.TagListMessage, 0, len(clusters))
 
-	for _, c := range clusters.CacheClusters {
+	for _, c := range clusters {
 		req, out := el.session.ListTagsForResourceRequest(&elasticache.ListTagsForResourceInput{
 			ResourceName: el.generateARN(c.CacheClusterId),
 		})
@@ -77,9 +88,9 @@ func (el *elasticacheLister) List() ([]tags, error) {
 		outs = append(outs, out)
 	}
 
-	errs := makeConcurrentRequests(reqs, "elasticache")
+	errs := makeConcurrentRequests(ctx, reqs, "elasticache")
 
-	tagsList := make([]tags, 0, len(clusters.CacheClusters))
+	tagsList := make([]tags, 0, len(clusters))
 	for i := range errs {
 		RequestTotalMetric.With(prometheus.Labels{"service": "elasticache", "region": el.region}).Inc()
 		if errs[i] != nil {
@@ -93,7 +104,12 @@ func (el *elasticacheLister) List() ([]tags, error) {
 		}
 
 		ts.keys = append(ts.keys, elasticacheCollector.defaultLabels...)
-		ts.values = append(ts.values, *clusters.CacheClusters[i].CacheClusterId, "cluster", el.region)
+		ts.values = append(ts.values, *clusters[i].CacheClusterId, "cluster", el.region)
+
+		for _, t := range outs[i].TagList {
+			ts.keys = append(ts.keys, *t.Key)
+			ts.values = append(ts.values, *t.Value)
+		}
 
 		tagsList = append(tagsList, ts)
 	}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elasticache/elasticacheiface"
+)
+
+type fakeElasticache struct {
+	elasticacheiface.ElastiCacheAPI
+	pages   [][]string                    // pages are the cluster IDs returned by each page of DescribeCacheClusters
+	tags    map[string][]*elasticache.Tag // tags are the tags of each cluster ARN
+	failing map[string]bool               // failing are the cluster ARNs whose ListTagsForResource request fails
+	listErr error
+}
+
+func (f *fakeElasticache) DescribeCacheClustersPagesWithContext(ctx aws.Context, in *elasticache.DescribeCacheClustersInput, fn func(*elasticache.DescribeCacheClustersOutput, bool) bool, opts ...request.Option) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
+	for i, page := range f.pages {
+		out := &elasticache.DescribeCacheClustersOutput{}
+		for _, id := range page {
+			out.CacheClusters = append(out.CacheClusters, &elasticache.CacheCluster{CacheClusterId: aws.String(id)})
+		}
+		if !fn(out, i == len(f.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeElasticache) ListTagsForResourceRequest(in *elasticache.ListTagsForResourceInput) (*request.Request, *elasticache.TagListMessage) {
+	if f.failing[*in.ResourceName] {
+		return fakeRequest(errFake), &elasticache.TagListMessage{}
+	}
+	return fakeRequest(nil), &elasticache.TagListMessage{TagList: f.tags[*in.ResourceName]}
+}
+
+// elasticacheARN returns the ARN of the cache cluster in the test region and account.
+func elasticacheARN(id string) string {
+	return arn.ARN{Partition: "aws", Service: "elasticache", Region: testRegion, AccountID: testAccountID, Resource: "cluster:" + id}.String()
+}
+
+func TestElasticacheLister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name:   "clusters across pages",
+			lister: newElasticacheLister(&fakeElasticache{pages: [][]string{{"sessions"}, {"cache"}}}),
+			resources: []string{
+				"name=cache,resource_type=cluster,region=eu-west-1",
+				"name=sessions,resource_type=cluster,region=eu-west-1",
+			},
+		},
+		{
+			name: "failed tags request skips the cluster",
+			lister: newElasticacheLister(&fakeElasticache{
+				pages:   [][]string{{"sessions", "cache"}},
+				failing: map[string]bool{elasticacheARN("sessions"): true},
+			}),
+			resources: []string{"name=cache,resource_type=cluster,region=eu-west-1"},
+		},
+		{
+			name:   "failed list",
+			lister: newElasticacheLister(&fakeElasticache{listErr: errFake}),
+			err:    true,
+		},
+	})
+}
@@ -1,10 +1,11 @@
 package collector
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -14,48 +15,59 @@ var (
 )
 
 var elbCollector = TagsCollector{
+	service:       "elb",
 	name:          prometheus.BuildFQName(namespace, "elb", "tags"),
 	help:          "AWS ELB tags converted to Prometheus labels.",
 	defaultLabels: []string{"load_balancer_name", "region"},
-	lister:        &elbLister{},
+	newLister:     func() tagsLister { return newELBLister(nil) },
 }
 
 type elbLister struct {
-	region  string
-	session *elb.ELB
+	listerConfig
+	session elbiface.ELBAPI
 }
 
-func (el *elbLister) Initialise(region string) (err error) {
-	el.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &el.region})
-	if err != nil {
-		return
+// newELBLister creates an elbLister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newELBLister(client elbiface.ELBAPI) *elbLister {
+	return &elbLister{session: client}
+}
+
+func (el *elbLister) Initialise(cfg listerConfig) error {
+	el.listerConfig = cfg
+	if el.session == nil {
+		el.session = elb.New(cfg.sess)
 	}
-	el.session = elb.New(sess)
-	return
+	return nil
 }
 
-func (el *elbLister) List() ([]tags, error) {
-	elbs, err := el.session.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{PageSize: &elbMaxRecords})
+func (el *elbLister) List(ctx context.Context) ([]tags, error) {
+	descriptions := make([]*elb.LoadBalancerDescription, 0)
+	pages := 0
+	err := el.session.DescribeLoadBalancersPagesWithContext(ctx, &elb.DescribeLoadBalancersInput{PageSize: &elbMaxRecords}, func(elbs *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "elb", "region": el.region}).Inc()
+		descriptions = append(descriptions, elbs.LoadBalancerDescriptions...)
+		return el.nextPage("elb", &pages, lastPage)
+	})
 
-	RequestTotalMetric.With(prometheus.Labels{"service": "elb", "region": el.region}).Inc()
 	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "elb", "region": el.region}).Inc()
 		RequestErrorTotalMetric.With(prometheus.Labels{"service": "elb", "region": el.region}).Inc()
 		return []tags{}, err
 	}
 
-	elbNames := make([]*string, 0, len(elbs.LoadBalancerDescriptions))
-	for _, description := range elbs.LoadBalancerDescriptions {
+	elbNames := make([]*string, 0, len(descriptions))
+	for _, description := range descriptions {
 		elbNames = append(elbNames, description.LoadBalancerName)
 	}
 
-	numReqs := len(elbs.LoadBalancerDescriptions)/describeELBTagsBatch + 1
+	numReqs := len(descriptions)/describeELBTagsBatch + 1
 	reqs := make([]*request.Request, 0, numReqs)
 	outs := make([]*elb.DescribeTagsOutput, 0, numReqs)
-	for i := 0; i < len(elbs.LoadBalancerDescriptions); i += describeELBTagsBatch {
+	for i := 0; i < len(descriptions); i += describeELBTagsBatch {
 		j := i + describeELBTagsBatch
-		if j > len(elbs.LoadBalancerDescriptions) {
-			j = len(elbs.LoadBalancerDescriptions)
+		if j > len(descriptions) {
+			j = len(descriptions)
 		}
 
 		req, out := el.session.DescribeTagsRequest(&elb.DescribeTagsInput{
@@ -65,9 +77,9 @@ func (el *elbLister) List() ([]tags, error) {
 		outs = append(outs, out)
 	}
 
-	errs := makeConcurrentRequests(reqs, "elb")
+	errs := makeConcurrentRequests(ctx, reqs, "elb")
 
-	tagsList := make([]tags, 0, len(elbs.LoadBalancerDescriptions))
+	tagsList := make([]tags, 0, len(descriptions))
 	for i := range errs {
 		RequestTotalMetric.With(prometheus.Labels{"service": "elb", "region": el.region}).Inc()
 		if errs[i] != nil {
@@ -84,6 +96,11 @@ func (el *elbLister) List() ([]tags, error) {
 			ts.keys = append(ts.keys, elbCollector.defaultLabels...)
 			ts.values = append(ts.values, *tagDesc.LoadBalancerName, el.region)
 
+			for _, t := range tagDesc.Tags {
+				ts.keys = append(ts.keys, *t.Key)
+				ts.values = append(ts.values, *t.Value)
+			}
+
 			tagsList = append(tagsList, ts)
 		}
 	}
@@ -1,30 +1,76 @@
 package collector
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 )
 
-func TestAWSTagDescriptionToPrometheusLabels(t *testing.T) {
-	lbn := "elb"
-	tk, tv := "name", "elb"
-	tags := make([]*elb.Tag, 1)
-	tags[0] = &elb.Tag{
-		Key:   &tk,
-		Value: &tv,
+type fakeELB struct {
+	elbiface.ELBAPI
+	pages   [][]string            // pages are the load balancer names returned by each page of DescribeLoadBalancers
+	tags    map[string][]*elb.Tag // tags are the tags of each load balancer
+	failing map[string]bool       // failing are the load balancers whose DescribeTags request fails
+	listErr error
+}
+
+func (f *fakeELB) DescribeLoadBalancersPagesWithContext(ctx aws.Context, in *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	if f.listErr != nil {
+		return f.listErr
 	}
-	td := &elb.TagDescription{
-		LoadBalancerName: &lbn,
-		Tags:             tags,
+	for i, page := range f.pages {
+		out := &elb.DescribeLoadBalancersOutput{}
+		for _, name := range page {
+			out.LoadBalancerDescriptions = append(out.LoadBalancerDescriptions, &elb.LoadBalancerDescription{LoadBalancerName: aws.String(name)})
+		}
+		if !fn(out, i == len(f.pages)-1) {
+			break
+		}
 	}
+	return nil
+}
 
-	lk, lv := awsTagDescriptionToPrometheusLabels(*td)
-
-	if lk[0] != *tags[0].Key {
-		t.Errorf("Label key of tag key %s should be %s, not %s", *tags[0].Key, *tags[0].Key, lk[0])
+func (f *fakeELB) DescribeTagsRequest(in *elb.DescribeTagsInput) (*request.Request, *elb.DescribeTagsOutput) {
+	out := &elb.DescribeTagsOutput{}
+	for _, name := range in.LoadBalancerNames {
+		if f.failing[*name] {
+			return fakeRequest(errFake), &elb.DescribeTagsOutput{}
+		}
+		out.TagDescriptions = append(out.TagDescriptions, &elb.TagDescription{LoadBalancerName: name, Tags: f.tags[*name]})
 	}
-	if lv[0] != *tags[0].Value {
-		t.Errorf("Label value of tag value %s should be %s, not %s", *tags[0].Value, *tags[0].Value, lv[0])
+	return fakeRequest(nil), out
+}
+
+func TestELBLister(t *testing.T) {
+	// More load balancers than are described in a single DescribeTags request
+	var names, resources []string
+	for i := 0; i < describeELBTagsBatch+5; i++ {
+		names = append(names, fmt.Sprintf("lb-%02d", i))
+		resources = append(resources, fmt.Sprintf("load_balancer_name=lb-%02d,region=eu-west-1", i))
 	}
+
+	runListerTests(t, []listerTest{
+		{
+			name:      "load balancers across pages and tag batches",
+			lister:    newELBLister(&fakeELB{pages: [][]string{names[:10], names[10:]}}),
+			resources: resources,
+		},
+		{
+			name: "failed tags request skips its batch",
+			lister: newELBLister(&fakeELB{
+				pages:   [][]string{names},
+				failing: map[string]bool{"lb-00": true},
+			}),
+			resources: resources[describeELBTagsBatch:],
+		},
+		{
+			name:   "failed list",
+			lister: newELBLister(&fakeELB{listErr: errFake}),
+			err:    true,
+		},
+	})
 }
@@ -1,10 +1,11 @@
 package collector
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -13,48 +14,61 @@ var (
 )
 
 var elbv2Collector = TagsCollector{
+	service:       "elbv2",
 	name:          prometheus.BuildFQName(namespace, "elbv2", "tags"),
 	help:          "AWS ELBv2 tags converted to Prometheus labels.",
 	defaultLabels: []string{"load_balancer_name", "region"},
-	lister:        &elbv2Lister{},
+	newLister:     func() tagsLister { return newELBV2Lister(nil) },
 }
 
 type elbv2Lister struct {
-	region  string
-	session *elbv2.ELBV2
+	listerConfig
+	session elbv2iface.ELBV2API
 }
 
-func (el *elbv2Lister) Initialise(region string) (err error) {
-	el.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &el.region})
-	if err != nil {
-		return
+// newELBV2Lister creates an elbv2Lister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newELBV2Lister(client elbv2iface.ELBV2API) *elbv2Lister {
+	return &elbv2Lister{session: client}
+}
+
+func (el *elbv2Lister) Initialise(cfg listerConfig) error {
+	el.listerConfig = cfg
+	if el.session == nil {
+		el.session = elbv2.New(cfg.sess)
 	}
-	el.session = elbv2.New(sess)
-	return
+	return nil
 }
 
-func (el *elbv2Lister) List() ([]tags, error) {
-	elbs, err := el.session.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{})
+func (el *elbv2Lister) List(ctx context.Context) ([]tags, error) {
+	loadBalancers := make([]*elbv2.LoadBalancer, 0)
+	pages := 0
+	err := el.session.DescribeLoadBalancersPagesWithContext(ctx, &elbv2.DescribeLoadBalancersInput{}, func(elbs *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "elbv2", "region": el.region}).Inc()
+		loadBalancers = append(loadBalancers, elbs.LoadBalancers...)
+		return el.nextPage("elbv2", &pages, lastPage)
+	})
 
-	RequestTotalMetric.With(prometheus.Labels{"service": "elbv2", "region": el.region}).Inc()
 	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "elbv2", "region": el.region}).Inc()
 		RequestErrorTotalMetric.With(prometheus.Labels{"service": "elbv2", "region": el.region}).Inc()
 		return []tags{}, err
 	}
 
-	elbv2Arns := make([]*string, 0, len(elbs.LoadBalancers))
-	for _, description := range elbs.LoadBalancers {
+	elbv2Arns := make([]*string, 0, len(loadBalancers))
+	elbv2Names := make(map[string]string, len(loadBalancers))
+	for _, description := range loadBalancers {
 		elbv2Arns = append(elbv2Arns, description.LoadBalancerArn)
+		elbv2Names[*description.LoadBalancerArn] = *description.LoadBalancerName
 	}
 
-	numReqs := len(elbs.LoadBalancers)/describeELBV2TagsBatch + 1
+	numReqs := len(loadBalancers)/describeELBV2TagsBatch + 1
 	reqs := make([]*request.Request, 0, numReqs)
 	outs := make([]*elbv2.DescribeTagsOutput, 0, numReqs)
-	for i := 0; i < len(elbs.LoadBalancers); i += describeELBV2TagsBatch {
+	for i := 0; i < len(loadBalancers); i += describeELBV2TagsBatch {
 		j := i + describeELBV2TagsBatch
-		if j > len(elbs.LoadBalancers) {
-			j = len(elbs.LoadBalancers)
+		if j > len(loadBalancers) {
+			j = len(loadBalancers)
 		}
 
 		req, out := el.session.DescribeTagsRequest(&elbv2.DescribeTagsInput{
@@ -64,9 +78,9 @@ func (el *elbv2Lister) List() ([]tags, error) {
 		outs = append(outs, out)
 	}
 
-	errs := makeConcurrentRequests(reqs, "elbv2")
+	errs := makeConcurrentRequests(ctx, reqs, "elbv2")
 
-	tagsList := make([]tags, 0, len(elbs.LoadBalancers))
+	tagsList := make([]tags, 0, len(loadBalancers))
 	for i := range errs {
 		RequestTotalMetric.With(prometheus.Labels{"service": "elbv2", "region": el.region}).Inc()
 		if errs[i] != nil {
@@ -74,18 +88,20 @@ func (el *elbv2Lister) List() ([]tags, error) {
 			continue
 		}
 
-		for j, tagDesc := range outs[i].TagDescriptions {
+		// The tag descriptions are not necessarily in the order of the requested ARNs
+		for _, tagDesc := range outs[i].TagDescriptions {
 			ts := tags{
 				make([]string, 0, len(tagDesc.Tags)+len(elbv2Collector.defaultLabels)),
 				make([]string, 0, len(tagDesc.Tags)+len(elbv2Collector.defaultLabels)),
 			}
 
 			ts.keys = append(ts.keys, elbv2Collector.defaultLabels...)
-			ts.values = append(
-				ts.values,
-				*elbs.LoadBalancers[i*describeELBV2TagsBatch+j].LoadBalancerName, // calculates index in original list
-				el.region,
-			)
+			ts.values = append(ts.values, elbv2Names[*tagDesc.ResourceArn], el.region)
+
+			for _, t := range tagDesc.Tags {
+				ts.keys = append(ts.keys, *t.Key)
+				ts.values = append(ts.values, *t.Value)
+			}
 
 			tagsList = append(tagsList, ts)
 		}
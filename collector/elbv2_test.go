@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+)
+
+const (
+	fakeELBV2ARNPrefix = "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/"
+)
+
+type fakeELBV2 struct {
+	elbv2iface.ELBV2API
+	pages   [][]string              // pages are the load balancer names returned by each page of DescribeLoadBalancers
+	tags    map[string][]*elbv2.Tag // tags are the tags of each load balancer
+	failing map[string]bool         // failing are the load balancers whose DescribeTags request fails
+	listErr error
+}
+
+func (f *fakeELBV2) DescribeLoadBalancersPagesWithContext(ctx aws.Context, in *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
+	for i, page := range f.pages {
+		out := &elbv2.DescribeLoadBalancersOutput{}
+		for _, name := range page {
+			out.LoadBalancers = append(out.LoadBalancers, &elbv2.LoadBalancer{
+				LoadBalancerName: aws.String(name),
+				LoadBalancerArn:  aws.String(fakeELBV2ARNPrefix + name),
+			})
+		}
+		if !fn(out, i == len(f.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeELBV2) DescribeTagsRequest(in *elbv2.DescribeTagsInput) (*request.Request, *elbv2.DescribeTagsOutput) {
+	out := &elbv2.DescribeTagsOutput{}
+	for _, arn := range in.ResourceArns {
+		name := strings.TrimPrefix(*arn, fakeELBV2ARNPrefix)
+		if f.failing[name] {
+			return fakeRequest(errFake), &elbv2.DescribeTagsOutput{}
+		}
+		out.TagDescriptions = append(out.TagDescriptions, &elbv2.TagDescription{ResourceArn: arn, Tags: f.tags[name]})
+	}
+	return fakeRequest(nil), out
+}
+
+func TestELBV2Lister(t *testing.T) {
+	// More load balancers than are described in a single DescribeTags request
+	var names, resources []string
+	for i := 0; i < describeELBV2TagsBatch+5; i++ {
+		names = append(names, fmt.Sprintf("alb-%02d", i))
+		resources = append(resources, fmt.Sprintf("load_balancer_name=alb-%02d,region=eu-west-1", i))
+	}
+
+	runListerTests(t, []listerTest{
+		{
+			name:      "load balancers across pages and tag batches",
+			lister:    newELBV2Lister(&fakeELBV2{pages: [][]string{names[:10], names[10:]}}),
+			resources: resources,
+		},
+		{
+			name: "failed tags request skips its batch",
+			lister: newELBV2Lister(&fakeELBV2{
+				pages:   [][]string{names},
+				failing: map[string]bool{"alb-00": true},
+			}),
+			resources: resources[describeELBV2TagsBatch:],
+		},
+		{
+			name:   "failed list",
+			lister: newELBV2Lister(&fakeELBV2{listErr: errFake}),
+			err:    true,
+		},
+	})
+}
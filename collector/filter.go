@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// awsReservedPrefix is the prefix of tag keys reserved for use by AWS
+	awsReservedPrefix = "aws:"
+)
+
+// TagRule matches tag keys, either exactly or with a regular expression.
+type TagRule struct {
+	key string
+	re  *regexp.Regexp // re is nil for exact rules
+}
+
+// ParseTagRule parses a TagRule.
+// A rule enclosed in slashes (e.g. /^kubernetes\.io\/cluster\/.*/) is a regular expression
+// that must match the whole key, otherwise the rule matches the key exactly.
+func ParseTagRule(s string) (TagRule, error) {
+	if len(s) < 2 || !strings.HasPrefix(s, "/") || !strings.HasSuffix(s, "/") {
+		return TagRule{key: s}, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + s[1:len(s)-1] + ")$")
+	if err != nil {
+		return TagRule{}, fmt.Errorf("invalid tag rule %q: %v", s, err)
+	}
+	return TagRule{key: s, re: re}, nil
+}
+
+func (r TagRule) String() string {
+	return r.key
+}
+
+func (r TagRule) matches(key string) bool {
+	if r.re != nil {
+		return r.re.MatchString(key)
+	}
+	return r.key == key
+}
+
+// TagFilter decides which tags are converted to labels.
+// A tag is kept if it matches any include rule, or there are none, and no exclude rule.
+// Rules are matched against the tag key as returned by AWS, before it is sanitized.
+type TagFilter struct {
+	Include []TagRule
+	Exclude []TagRule
+	// DropAWSReserved drops the tags reserved for use by AWS (those whose keys start with "aws:").
+	DropAWSReserved bool
+}
+
+// Merge returns a TagFilter with the rules of both filters.
+func (f TagFilter) Merge(o TagFilter) TagFilter {
+	return TagFilter{
+		Include:         append(append([]TagRule{}, f.Include...), o.Include...),
+		Exclude:         append(append([]TagRule{}, f.Exclude...), o.Exclude...),
+		DropAWSReserved: f.DropAWSReserved || o.DropAWSReserved,
+	}
+}
+
+// keep reports whether the tag with the specified key should be converted to a label.
+func (f TagFilter) keep(key string) bool {
+	if f.DropAWSReserved && strings.HasPrefix(key, awsReservedPrefix) {
+		return false
+	}
+
+	for _, r := range f.Exclude {
+		if r.matches(key) {
+			return false
+		}
+	}
+
+	if len(f.Include) == 0 {
+		return true
+	}
+
+	for _, r := range f.Include {
+		if r.matches(key) {
+			return true
+		}
+	}
+	return false
+}
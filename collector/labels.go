@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// tagLabelPrefix is prepended to tag keys in prefix mode or when they would otherwise collide with another label
+	tagLabelPrefix = "tag_"
+)
+
+// CollisionPolicy determines what happens to a tag whose sanitized key collides with another label.
+// It implements flag.Value.
+type CollisionPolicy string
+
+const (
+	// CollisionPrefix prefixes the colliding tag key with "tag_" until it is unique.
+	CollisionPrefix CollisionPolicy = "prefix"
+	// CollisionDrop drops the colliding tag.
+	CollisionDrop CollisionPolicy = "drop"
+	// CollisionMerge joins the values of colliding tags with ",".
+	// A tag that collides with a default label is prefixed instead.
+	CollisionMerge CollisionPolicy = "merge"
+)
+
+func (p *CollisionPolicy) String() string {
+	return string(*p)
+}
+
+// Set sets the policy, returning an error if it is not one of prefix, drop or merge.
+func (p *CollisionPolicy) Set(value string) error {
+	switch CollisionPolicy(value) {
+	case CollisionPrefix, CollisionDrop, CollisionMerge:
+		*p = CollisionPolicy(value)
+		return nil
+	default:
+		return fmt.Errorf("unknown collision policy %q, expected one of prefix, drop or merge", value)
+	}
+}
+
+// labelOptions configure how tags are converted to labels.
+type labelOptions struct {
+	collision CollisionPolicy // collision is how tags colliding with another label are handled
+	prefix    bool            // prefix is true if every tag label name is prefixed with "tag_"
+	filter    TagFilter       // filter decides which tags are converted to labels
+	schema    []string        // schema is the fixed set of tag keys converted to labels, if any
+}
+
+// labelName converts a tag key to a label name.
+func (opts labelOptions) labelName(key string) string {
+	if opts.prefix {
+		return sanitizeLabelName(tagLabelPrefix + key)
+	}
+	return sanitizeLabelName(key)
+}
+
+// schemaLabels returns the label names of the schema's tag keys.
+// It returns an error if any of them collide with each other or with the default labels.
+func (opts labelOptions) schemaLabels(defaultLabels []string) ([]string, error) {
+	used := make(map[string]struct{}, len(defaultLabels)+len(opts.schema))
+	for _, l := range defaultLabels {
+		used[l] = struct{}{}
+	}
+
+	names := make([]string, 0, len(opts.schema))
+	for _, key := range opts.schema {
+		name := opts.labelName(key)
+		if _, ok := used[name]; ok {
+			return nil, fmt.Errorf("tag %q collides with another label named %q", key, name)
+		}
+		used[name] = struct{}{}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// tag is a single AWS tag.
+type tag struct {
+	key   string
+	value string
+}
+
+// labels returns the Prometheus label names and values for the tags.
+// The first n keys are default labels and are kept as they are. The remaining tags are filtered, their keys sanitized,
+// prefixed in prefix mode, and any collisions with earlier labels are resolved according to the collision policy.
+// Tags are handled in key order so that collisions are always resolved the same way.
+func (ls *tags) labels(n int, opts labelOptions) (keys, values []string, collisions int) {
+	keys = append(make([]string, 0, len(ls.keys)), ls.keys[:n]...)
+	values = append(make([]string, 0, len(ls.values)), ls.values[:n]...)
+
+	// index maps a label name to its position in keys
+	index := make(map[string]int, len(ls.keys))
+	for i, k := range keys {
+		index[k] = i
+	}
+
+	tagList := make([]tag, 0, len(ls.keys)-n)
+	for i := n; i < len(ls.keys); i++ {
+		if opts.filter.keep(ls.keys[i]) {
+			tagList = append(tagList, tag{ls.keys[i], ls.values[i]})
+		}
+	}
+	sort.Slice(tagList, func(i, j int) bool {
+		if tagList[i].key == tagList[j].key {
+			return tagList[i].value < tagList[j].value
+		}
+		return tagList[i].key < tagList[j].key
+	})
+
+	for _, t := range tagList {
+		name := opts.labelName(t.key)
+		if i, ok := index[name]; ok {
+			collisions++
+			if opts.collision == CollisionDrop {
+				continue
+			}
+			if opts.collision == CollisionMerge && i >= n {
+				values[i] += "," + t.value
+				continue
+			}
+
+			for ok {
+				name = tagLabelPrefix + name
+				_, ok = index[name]
+			}
+		}
+
+		index[name] = len(keys)
+		keys = append(keys, name)
+		values = append(values, t.value)
+	}
+
+	return
+}
+
+// schemaValues returns the label values for the tags in schema mode.
+// The first n values are the default labels, followed by the value of each of the schema's
+// tag keys, or an empty string if the resource does not have the tag.
+func (ls *tags) schemaValues(n int, schema []string) []string {
+	tagMap := make(map[string]string, len(ls.keys)-n)
+	for i := n; i < len(ls.keys); i++ {
+		tagMap[ls.keys[i]] = ls.values[i]
+	}
+
+	values := append(make([]string, 0, n+len(schema)), ls.values[:n]...)
+	for _, key := range schema {
+		values = append(values, tagMap[key])
+	}
+
+	return values
+}
@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestLabelsCollisions(t *testing.T) {
+	ts := tags{
+		keys:   []string{"name", "region", "team_name", "region", "team-name", "1st", "__x", "_ x"},
+		values: []string{"db", "eu-west-1", "b", "us-east-1", "a", "x", "z", "y"},
+	}
+
+	tests := []struct {
+		policy     CollisionPolicy
+		prefix     bool
+		keys       []string
+		values     []string
+		collisions int
+	}{
+		{
+			policy:     CollisionPrefix,
+			keys:       []string{"name", "region", "_1st", "_x", "tag__x", "tag_region", "team_name", "tag_team_name"},
+			values:     []string{"db", "eu-west-1", "x", "y", "z", "us-east-1", "a", "b"},
+			collisions: 3,
+		},
+		{
+			policy:     CollisionDrop,
+			keys:       []string{"name", "region", "_1st", "_x", "team_name"},
+			values:     []string{"db", "eu-west-1", "x", "y", "a"},
+			collisions: 3,
+		},
+		{
+			policy:     CollisionMerge,
+			keys:       []string{"name", "region", "_1st", "_x", "tag_region", "team_name"},
+			values:     []string{"db", "eu-west-1", "x", "y,z", "us-east-1", "a,b"},
+			collisions: 3,
+		},
+		{
+			policy:     CollisionPrefix,
+			prefix:     true,
+			keys:       []string{"name", "region", "tag_1st", "tag___x", "tag_tag___x", "tag_region", "tag_team_name", "tag_tag_team_name"},
+			values:     []string{"db", "eu-west-1", "x", "y", "z", "us-east-1", "a", "b"},
+			collisions: 2,
+		},
+	}
+
+	for _, test := range tests {
+		keys, values, collisions := ts.labels(2, labelOptions{collision: test.policy, prefix: test.prefix})
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%s: keys should be %v, not %v", test.policy, test.keys, keys)
+		}
+		if !reflect.DeepEqual(values, test.values) {
+			t.Errorf("%s: values should be %v, not %v", test.policy, test.values, values)
+		}
+		if collisions != test.collisions {
+			t.Errorf("%s: collisions should be %d, not %d", test.policy, test.collisions, collisions)
+		}
+	}
+}
+
+func TestLabelsFilter(t *testing.T) {
+	ts := tags{
+		keys:   []string{"name", "Name", "aws:cloudformation:stack-name", "kubernetes.io/cluster/prod", "team", "cost-centre"},
+		values: []string{"db", "db", "stack", "owned", "infra", "42"},
+	}
+
+	mustParse := func(s string) TagRule {
+		r, err := ParseTagRule(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	tests := []struct {
+		filter TagFilter
+		keys   []string
+	}{
+		{
+			filter: TagFilter{},
+			keys:   []string{"name", "Name", "aws_cloudformation_stack_name", "cost_centre", "kubernetes_io_cluster_prod", "team"},
+		},
+		{
+			filter: TagFilter{DropAWSReserved: true, Exclude: []TagRule{mustParse(`/kubernetes\.io\/.*/`)}},
+			keys:   []string{"name", "Name", "cost_centre", "team"},
+		},
+		{
+			filter: TagFilter{Include: []TagRule{mustParse("team"), mustParse("/cost-.*/")}, Exclude: []TagRule{mustParse("cost-centre")}},
+			keys:   []string{"name", "team"},
+		},
+	}
+
+	for i, test := range tests {
+		keys, _, _ := ts.labels(1, labelOptions{collision: CollisionPrefix, filter: test.filter})
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%d: keys should be %v, not %v", i, test.keys, keys)
+		}
+	}
+}
+
+func TestSchemaLabels(t *testing.T) {
+	opts := labelOptions{schema: []string{"team", "cost-centre", "region"}}
+	if _, err := opts.schemaLabels([]string{"account_id", "region"}); err == nil {
+		t.Error("Schema key region should collide with the region label")
+	}
+
+	opts.prefix = true
+	names, err := opts.schemaLabels([]string{"account_id", "region"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tag_team", "tag_cost_centre", "tag_region"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Schema labels should be %v, not %v", want, names)
+	}
+
+	ts := tags{
+		keys:   []string{"account_id", "region", "region", "team", "Name"},
+		values: []string{"123456789012", "eu-west-1", "eu", "infra", "db"},
+	}
+	if want, values := []string{"123456789012", "eu-west-1", "infra", "", "eu"}, ts.schemaValues(2, opts.schema); !reflect.DeepEqual(values, want) {
+		t.Errorf("Schema values should be %v, not %v", want, values)
+	}
+}
+
+func TestWithLabelOptions(t *testing.T) {
+	client := &fakeEC2{pages: [][]*ec2.TagDescription{{ec2Tag("i-1", "instance", "Name", "web")}}}
+	tc := ec2Collector
+	tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: newEC2Lister(client)}}
+	if err := tc.targets[0].lister.Initialise(listerConfig{region: testRegion, accountID: testAccountID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.setLabelOptions(Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tc.list(context.Background(), tc.targets[0]); err != nil {
+		t.Fatal(err)
+	}
+	// Serve the cached tags so that the relabelled collector cannot list them again.
+	tc.background = true
+	client.err = errFake
+
+	relabelled, err := tc.WithLabelOptions(Options{TagPrefix: true, PageLimit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relabelled.targets[0] != tc.targets[0] {
+		t.Error("The relabelled collector should share the targets of the collector")
+	}
+	series := collectFrom(t, relabelled)
+	if len(series) != 1 || series[0]["tag_Name"] != "web" {
+		t.Errorf("The relabelled collector should serve the cached tags with the new labels, not %v", series)
+	}
+	if series := collectFrom(t, &tc); len(series) != 1 || series[0]["Name"] != "web" {
+		t.Errorf("The collector should keep its labels, not %v", series)
+	}
+
+	if _, err := tc.WithLabelOptions(Options{TagSchema: []string{"region"}}); err == nil {
+		t.Error("A schema that collides with a default label should be rejected")
+	}
+}
+
+func TestOptionsSameListing(t *testing.T) {
+	opts := Options{PageLimit: 10, ResourceTypes: []string{"s3"}}
+	tests := []struct {
+		change func(o *Options)
+		same   bool
+	}{
+		{change: func(o *Options) {}, same: true},
+		{change: func(o *Options) { o.LabelCollision = CollisionDrop }, same: true},
+		{change: func(o *Options) { o.TagPrefix = true }, same: true},
+		{change: func(o *Options) { o.TagFilter = TagFilter{DropAWSReserved: true} }, same: true},
+		{change: func(o *Options) { o.TagSchema = []string{"team"} }, same: true},
+		{change: func(o *Options) { o.PageLimit = 1 }, same: false},
+		{change: func(o *Options) { o.RefreshInterval = time.Minute }, same: false},
+		{change: func(o *Options) { o.Retry.MaxRetries = 1 }, same: false},
+		{change: func(o *Options) { o.Timeout = time.Second }, same: false},
+		{change: func(o *Options) { o.ResourceTypes = []string{"ec2:instance"} }, same: false},
+	}
+
+	for i, test := range tests {
+		changed := opts
+		test.change(&changed)
+		if same := opts.SameListing(changed); same != test.same {
+			t.Errorf("%d: %+v and %+v should list the same tags: %t", i, opts, changed, test.same)
+		}
+	}
+}
+
+func TestSchemaFilter(t *testing.T) {
+	tc := ec2Collector
+	filter := TagFilter{DropAWSReserved: true}
+	if err := tc.setLabelOptions(Options{TagSchema: []string{"team", "aws:cloudformation:stack-name"}, TagFilter: filter}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"team"}; !reflect.DeepEqual(tc.schemaLabels, want) {
+		t.Errorf("The schema labels should only be the tags kept by the filter %v, not %v", want, tc.schemaLabels)
+	}
+
+	if err := tc.setLabelOptions(Options{TagSchema: []string{"aws:cloudformation:stack-name"}, TagFilter: filter}); err == nil {
+		t.Error("A schema whose every tag key is filtered should be rejected")
+	}
+}
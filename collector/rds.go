@@ -1,10 +1,12 @@
 package collector
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -13,38 +15,50 @@ var (
 )
 
 var rdsCollector = TagsCollector{
+	service:       "rds",
 	name:          prometheus.BuildFQName(namespace, "rds", "tags"),
 	help:          "AWS RDS tags converted to Prometheus labels.",
-	defaultLabels: []string{"name", "identifier", "availability_zone"},
-	lister:        &rdsLister{},
+	defaultLabels: []string{"name", "identifier", "availability_zone", "region"},
+	newLister:     func() tagsLister { return newRDSLister(nil) },
 }
 
 type rdsLister struct {
-	region  string
-	session *rds.RDS
+	listerConfig
+	session rdsiface.RDSAPI
 }
 
-func (rd *rdsLister) Initialise(region string) (err error) {
-	rd.region = region
-	sess, err := session.NewSession(&aws.Config{Region: &rd.region})
-	if err != nil {
-		return
+// newRDSLister creates an rdsLister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newRDSLister(client rdsiface.RDSAPI) *rdsLister {
+	return &rdsLister{session: client}
+}
+
+func (rd *rdsLister) Initialise(cfg listerConfig) error {
+	rd.listerConfig = cfg
+	if rd.session == nil {
+		rd.session = rds.New(cfg.sess)
 	}
-	rd.session = rds.New(sess)
-	return
+	return nil
 }
 
-func (rd *rdsLister) List() ([]tags, error) {
-	dbs, err := rd.session.DescribeDBInstances(&rds.DescribeDBInstancesInput{MaxRecords: &rdsMaxRecords})
-	RequestTotalMetric.With(prometheus.Labels{"service": "rds", "region": rd.region}).Inc()
+func (rd *rdsLister) List(ctx context.Context) ([]tags, error) {
+	dbInstances := make([]*rds.DBInstance, 0)
+	pages := 0
+	err := rd.session.DescribeDBInstancesPagesWithContext(ctx, &rds.DescribeDBInstancesInput{MaxRecords: &rdsMaxRecords}, func(dbs *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "rds", "region": rd.region}).Inc()
+		dbInstances = append(dbInstances, dbs.DBInstances...)
+		return rd.nextPage("rds", &pages, lastPage)
+	})
+
 	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "rds", "region": rd.region}).Inc()
 		RequestErrorTotalMetric.With(prometheus.Labels{"service": "rds", "region": rd.region}).Inc()
 		return []tags{}, err
 	}
 
-	reqs := make([]*request.Request, 0, len(dbs.DBInstances))
-	outs := make([]*rds.ListTagsForResourceOutput, 0, len(dbs.DBInstances))
-	for _, db := range dbs.DBInstances {
+	reqs := make([]*request.Request, 0, len(dbInstances))
+	outs := make([]*rds.ListTagsForResourceOutput, 0, len(dbInstances))
+	for _, db := range dbInstances {
 		req, out := rd.session.ListTagsForResourceRequest(&rds.ListTagsForResourceInput{
 			ResourceName: aws.String(*db.DBInstanceArn),
 		})
@@ -52,9 +66,9 @@ func (rd *rdsLister) List() ([]tags, error) {
 		outs = append(outs, out)
 	}
 
-	errs := makeConcurrentRequests(reqs, "rds")
+	errs := makeConcurrentRequests(ctx, reqs, "rds")
 
-	tagsList := make([]tags, 0, len(dbs.DBInstances))
+	tagsList := make([]tags, 0, len(dbInstances))
 	for i := range errs {
 		RequestTotalMetric.With(prometheus.Labels{"service": "rds", "region": rd.region}).Inc()
 		if errs[i] != nil {
@@ -70,9 +84,10 @@ func (rd *rdsLister) List() ([]tags, error) {
 		ts.keys = append(ts.keys, rdsCollector.defaultLabels...)
 		ts.values = append(
 			ts.values,
-			*dbs.DBInstances[i].DBName,
-			*dbs.DBInstances[i].DBInstanceIdentifier,
-			*dbs.DBInstances[i].AvailabilityZone,
+			aws.StringValue(dbInstances[i].DBName),
+			aws.StringValue(dbInstances[i].DBInstanceIdentifier),
+			aws.StringValue(dbInstances[i].AvailabilityZone),
+			rd.region,
 		)
 
 		for _, t := range outs[i].TagList {
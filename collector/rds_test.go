@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+const (
+	fakeRDSARNPrefix = "arn:aws:rds:eu-west-1:123456789012:db:"
+)
+
+type fakeRDS struct {
+	rdsiface.RDSAPI
+	pages   [][]string            // pages are the instance identifiers returned by each page of DescribeDBInstances
+	tags    map[string][]*rds.Tag // tags are the tags of each instance
+	failing map[string]bool       // failing are the instances whose ListTagsForResource request fails
+	unnamed map[string]bool       // unnamed are the instances without a database name
+	listErr error
+}
+
+func (f *fakeRDS) DescribeDBInstancesPagesWithContext(ctx aws.Context, in *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool, opts ...request.Option) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
+	for i, page := range f.pages {
+		out := &rds.DescribeDBInstancesOutput{}
+		for _, id := range page {
+			db := &rds.DBInstance{
+				DBName:               aws.String(id + "_db"),
+				DBInstanceIdentifier: aws.String(id),
+				DBInstanceArn:        aws.String(fakeRDSARNPrefix + id),
+				AvailabilityZone:     aws.String("eu-west-1a"),
+			}
+			if f.unnamed[id] {
+				db.DBName = nil
+			}
+			out.DBInstances = append(out.DBInstances, db)
+		}
+		if !fn(out, i == len(f.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeRDS) ListTagsForResourceRequest(in *rds.ListTagsForResourceInput) (*request.Request, *rds.ListTagsForResourceOutput) {
+	id := strings.TrimPrefix(*in.ResourceName, fakeRDSARNPrefix)
+	if f.failing[id] {
+		return fakeRequest(errFake), &rds.ListTagsForResourceOutput{}
+	}
+	return fakeRequest(nil), &rds.ListTagsForResourceOutput{TagList: f.tags[id]}
+}
+
+func TestRDSLister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name: "instances across pages",
+			lister: newRDSLister(&fakeRDS{
+				pages: [][]string{{"users"}, {"orders"}},
+				tags: map[string][]*rds.Tag{
+					"users":  {{Key: aws.String("team"), Value: aws.String("identity")}},
+					"orders": {{Key: aws.String("team"), Value: aws.String("payments")}},
+				},
+			}),
+			resources: []string{
+				"name=orders_db,identifier=orders,availability_zone=eu-west-1a,region=eu-west-1,team=payments",
+				"name=users_db,identifier=users,availability_zone=eu-west-1a,region=eu-west-1,team=identity",
+			},
+		},
+		{
+			name: "failed tags request skips the instance",
+			lister: newRDSLister(&fakeRDS{
+				pages:   [][]string{{"users", "orders"}},
+				failing: map[string]bool{"users": true},
+			}),
+			resources: []string{"name=orders_db,identifier=orders,availability_zone=eu-west-1a,region=eu-west-1"},
+		},
+		{
+			name: "instance without a database name",
+			lister: newRDSLister(&fakeRDS{
+				pages:   [][]string{{"users"}},
+				unnamed: map[string]bool{"users": true},
+			}),
+			resources: []string{"name=,identifier=users,availability_zone=eu-west-1a,region=eu-west-1"},
+		},
+		{
+			name:   "failed list",
+			lister: newRDSLister(&fakeRDS{listErr: errFake}),
+			err:    true,
+		},
+	})
+}
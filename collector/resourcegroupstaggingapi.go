@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	resourceGroupsTaggingAPIMaxRecords int64 = 100
+)
+
+var resourceGroupsTaggingAPICollector = TagsCollector{
+	service:       "resourcegroupstaggingapi",
+	name:          prometheus.BuildFQName(namespace, "resource", "tags"),
+	help:          "AWS resource tags listed by the Resource Groups Tagging API converted to Prometheus labels.",
+	defaultLabels: []string{"arn", "service", "resource_type", "region"},
+	newLister:     func() tagsLister { return newResourceGroupsTaggingAPILister(nil) },
+	// It duplicates the series of the per-service collectors and needs tag:GetResources
+	optIn: true,
+}
+
+type resourceGroupsTaggingAPILister struct {
+	listerConfig
+	session resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+}
+
+// newResourceGroupsTaggingAPILister creates a resourceGroupsTaggingAPILister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newResourceGroupsTaggingAPILister(client resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI) *resourceGroupsTaggingAPILister {
+	return &resourceGroupsTaggingAPILister{session: client}
+}
+
+func (rg *resourceGroupsTaggingAPILister) Initialise(cfg listerConfig) error {
+	rg.listerConfig = cfg
+	if rg.session == nil {
+		rg.session = resourcegroupstaggingapi.New(cfg.sess)
+	}
+	return nil
+}
+
+// parseResourceARN returns the service and resource type of a resource from its ARN.
+// The resource type is the part of the resource before the first "/" or ":" (e.g. instance in
+// arn:aws:ec2:eu-west-1:123456789012:instance/i-1) and is empty if there is none (e.g. for S3 buckets).
+func parseResourceARN(s string) (service, resourceType string, err error) {
+	parsed, err := arn.Parse(s)
+	if err != nil {
+		return "", "", err
+	}
+
+	if i := strings.IndexAny(parsed.Resource, "/:"); i >= 0 {
+		resourceType = parsed.Resource[:i]
+	}
+	return parsed.Service, resourceType, nil
+}
+
+func (rg *resourceGroupsTaggingAPILister) List(ctx context.Context) ([]tags, error) {
+	in := &resourcegroupstaggingapi.GetResourcesInput{ResourcesPerPage: &resourceGroupsTaggingAPIMaxRecords}
+	if len(rg.resourceTypes) > 0 {
+		in.ResourceTypeFilters = aws.StringSlice(rg.resourceTypes)
+	}
+
+	tagsList := make([]tags, 0)
+	pages := 0
+	err := rg.session.GetResourcesPagesWithContext(ctx, in, func(out *resourcegroupstaggingapi.GetResourcesOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "resourcegroupstaggingapi", "region": rg.region}).Inc()
+		for _, mapping := range out.ResourceTagMappingList {
+			service, resourceType, err := parseResourceARN(*mapping.ResourceARN)
+			if err != nil {
+				glog.Warningf("Skipping resource with invalid ARN %q: %v", *mapping.ResourceARN, err)
+				continue
+			}
+
+			ts := tags{
+				make([]string, 0, len(mapping.Tags)+len(resourceGroupsTaggingAPICollector.defaultLabels)),
+				make([]string, 0, len(mapping.Tags)+len(resourceGroupsTaggingAPICollector.defaultLabels)),
+			}
+
+			ts.keys = append(ts.keys, resourceGroupsTaggingAPICollector.defaultLabels...)
+			ts.values = append(ts.values, *mapping.ResourceARN, service, resourceType, rg.region)
+
+			for _, t := range mapping.Tags {
+				ts.keys = append(ts.keys, *t.Key)
+				ts.values = append(ts.values, *t.Value)
+			}
+
+			tagsList = append(tagsList, ts)
+		}
+
+		return rg.nextPage("resourcegroupstaggingapi", &pages, lastPage)
+	})
+
+	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "resourcegroupstaggingapi", "region": rg.region}).Inc()
+		RequestErrorTotalMetric.With(prometheus.Labels{"service": "resourcegroupstaggingapi", "region": rg.region}).Inc()
+		return []tags{}, err
+	}
+
+	return tagsList, nil
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type fakeResourceGroupsTaggingAPI struct {
+	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	pages   [][]*resourcegroupstaggingapi.ResourceTagMapping
+	filters []string // filters are the resource type filters of the last request
+	err     error
+}
+
+func (f *fakeResourceGroupsTaggingAPI) GetResourcesPagesWithContext(ctx aws.Context, in *resourcegroupstaggingapi.GetResourcesInput, fn func(*resourcegroupstaggingapi.GetResourcesOutput, bool) bool, opts ...request.Option) error {
+	f.filters = aws.StringValueSlice(in.ResourceTypeFilters)
+	if f.err != nil {
+		return f.err
+	}
+	for i, page := range f.pages {
+		if !fn(&resourcegroupstaggingapi.GetResourcesOutput{ResourceTagMappingList: page}, i == len(f.pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func resourceTagMapping(arn string, kvs ...string) *resourcegroupstaggingapi.ResourceTagMapping {
+	mapping := &resourcegroupstaggingapi.ResourceTagMapping{ResourceARN: aws.String(arn)}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		mapping.Tags = append(mapping.Tags, &resourcegroupstaggingapi.Tag{Key: aws.String(kvs[i]), Value: aws.String(kvs[i+1])})
+	}
+	return mapping
+}
+
+func TestResourceGroupsTaggingAPILister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name: "resources across pages",
+			lister: newResourceGroupsTaggingAPILister(&fakeResourceGroupsTaggingAPI{pages: [][]*resourcegroupstaggingapi.ResourceTagMapping{
+				{
+					resourceTagMapping("arn:aws:ec2:eu-west-1:123456789012:instance/i-1", "team", "infra"),
+					resourceTagMapping("arn:aws:rds:eu-west-1:123456789012:db:users", "team", "identity"),
+				},
+				{
+					resourceTagMapping("arn:aws:s3:::logs", "team", "sre"),
+					resourceTagMapping("not-an-arn", "team", "unknown"),
+				},
+			}}),
+			resources: []string{
+				"arn=arn:aws:ec2:eu-west-1:123456789012:instance/i-1,service=ec2,resource_type=instance,region=eu-west-1,team=infra",
+				"arn=arn:aws:rds:eu-west-1:123456789012:db:users,service=rds,resource_type=db,region=eu-west-1,team=identity",
+				"arn=arn:aws:s3:::logs,service=s3,resource_type=,region=eu-west-1,team=sre",
+			},
+		},
+		{
+			name:   "failed request",
+			lister: newResourceGroupsTaggingAPILister(&fakeResourceGroupsTaggingAPI{err: errFake}),
+			err:    true,
+		},
+	})
+}
+
+func TestResourceGroupsTaggingAPIResourceTypes(t *testing.T) {
+	fake := &fakeResourceGroupsTaggingAPI{}
+	lister := newResourceGroupsTaggingAPILister(fake)
+	if err := lister.Initialise(listerConfig{region: testRegion, resourceTypes: []string{"ec2:instance", "s3"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lister.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ec2:instance", "s3"}; !reflect.DeepEqual(fake.filters, want) {
+		t.Errorf("Resource type filters should be %v, not %v", want, fake.filters)
+	}
+}
+
+func TestResourceGroupsTaggingAPIGather(t *testing.T) {
+	tc := resourceGroupsTaggingAPICollector
+	tc.labelOpts = labelOptions{collision: CollisionPrefix}
+	tc.targets = []*target{
+		{region: "eu-west-1", accountID: testAccountID, lister: newResourceGroupsTaggingAPILister(&fakeResourceGroupsTaggingAPI{pages: [][]*resourcegroupstaggingapi.ResourceTagMapping{{
+			resourceTagMapping("arn:aws:ec2:eu-west-1:123456789012:instance/i-1", "team", "infra"),
+			resourceTagMapping("arn:aws:s3:::logs", "owner", "sre", "cost-centre", "42"),
+		}}})},
+		{region: "us-east-1", accountID: testAccountID, lister: newResourceGroupsTaggingAPILister(&fakeResourceGroupsTaggingAPI{pages: [][]*resourcegroupstaggingapi.ResourceTagMapping{{
+			resourceTagMapping("arn:aws:sqs:us-east-1:123456789012:jobs"),
+		}}})},
+	}
+	for _, target := range tc.targets {
+		if err := target.lister.Initialise(listerConfig{region: target.region, accountID: target.accountID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(&tc); err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Resources with different tags should be gathered: %v", err)
+	}
+	if len(families) != 1 || len(families[0].Metric) != 3 {
+		t.Fatalf("3 series of 1 metric should be gathered, not %v", families)
+	}
+
+	want := []string{"account_id", "arn", "cost_centre", "owner", "region", "resource_type", "service", "team"}
+	values := make(map[string]string)
+	for _, m := range families[0].Metric {
+		var names []string
+		labels := make(map[string]string, len(m.Label))
+		for _, l := range m.Label {
+			names = append(names, l.GetName())
+			labels[l.GetName()] = l.GetValue()
+		}
+		values[labels["arn"]] = labels["team"]
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("Every series should have the labels %v, not %v", want, names)
+		}
+	}
+	if values["arn:aws:ec2:eu-west-1:123456789012:instance/i-1"] != "infra" || values["arn:aws:s3:::logs"] != "" {
+		t.Errorf("Resources without a tag should have an empty label, not %v", values)
+	}
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// throttleErrorCodes are the AWS error codes returned when a request is throttled
+	throttleErrorCodes = map[string]struct{}{
+		"Throttling":                             {},
+		"ThrottlingException":                    {},
+		"ThrottledException":                     {},
+		"RequestThrottled":                       {},
+		"RequestThrottledException":              {},
+		"RequestLimitExceeded":                   {},
+		"TooManyRequestsException":               {},
+		"ProvisionedThroughputExceededException": {},
+		"PriorRequestNotComplete":                {},
+		"SlowDown":                               {},
+		"EC2ThrottledException":                  {},
+		"BandwidthLimitExceeded":                 {},
+	}
+)
+
+// RetryOptions configure how requests that fail transiently are retried.
+type RetryOptions struct {
+	// MaxRetries is the maximum number of times a request is retried.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry. It is doubled on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries.
+	MaxDelay time.Duration
+}
+
+// retryer is a request.Retryer that retries throttled and transient errors with jittered exponential backoff.
+// Every retry is counted by RequestRetriesTotalMetric.
+type retryer struct {
+	RetryOptions
+	service string
+	region  string
+}
+
+// MaxRetries is required to implement the request.Retryer interface.
+func (r retryer) MaxRetries() int {
+	return r.RetryOptions.MaxRetries
+}
+
+// ShouldRetry is required to implement the request.Retryer interface.
+// Throttled requests, server errors and the errors the SDK considers retryable are retried.
+func (r retryer) ShouldRetry(req *request.Request) bool {
+	if isThrottle(req.Error) {
+		return true
+	}
+	if req.HTTPResponse != nil && req.HTTPResponse.StatusCode >= 500 {
+		return true
+	}
+	return req.IsErrorRetryable()
+}
+
+// RetryRules is required to implement the request.Retryer interface.
+// The delay is chosen uniformly between half and all of BaseDelay * 2^RetryCount, capped at MaxDelay.
+func (r retryer) RetryRules(req *request.Request) time.Duration {
+	RequestRetriesTotalMetric.With(prometheus.Labels{"service": r.service, "region": r.region, "code": errorCode(req.Error)}).Inc()
+
+	delay := r.BaseDelay
+	for i := 0; i < req.RetryCount && delay < r.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isThrottle reports whether err is an AWS error returned because the request was throttled.
+func isThrottle(err error) bool {
+	_, ok := throttleErrorCodes[errorCode(err)]
+	return ok
+}
+
+// errorCode returns the AWS error code of err, or "unknown" if it is not an AWS error.
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return "unknown"
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+func TestRetryerShouldRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		retry  bool
+	}{
+		{name: "Throttling", err: awserr.New("Throttling", "Rate exceeded", nil), status: http.StatusBadRequest, retry: true},
+		{name: "RequestLimitExceeded", err: awserr.New("RequestLimitExceeded", "Request limit exceeded", nil), status: http.StatusServiceUnavailable, retry: true},
+		{name: "TooManyRequestsException", err: awserr.New("TooManyRequestsException", "Too many requests", nil), status: http.StatusTooManyRequests, retry: true},
+		{name: "internal error", err: awserr.New("InternalError", "internal error", nil), status: http.StatusInternalServerError, retry: true},
+		{name: "unavailable", err: awserr.New("Unavailable", "unavailable", nil), status: http.StatusServiceUnavailable, retry: true},
+		{name: "access denied", err: awserr.New("AccessDenied", "access denied", nil), status: http.StatusForbidden, retry: false},
+		{name: "validation", err: awserr.New("ValidationError", "invalid parameter", nil), status: http.StatusBadRequest, retry: false},
+		{name: "not found", err: awserr.New("DBInstanceNotFound", "not found", nil), status: http.StatusNotFound, retry: false},
+		{name: "canceled", err: awserr.New(request.CanceledErrorCode, "canceled", nil), retry: false},
+	}
+
+	r := retryer{RetryOptions: RetryOptions{MaxRetries: 3}, service: "retry-test", region: testRegion}
+	for _, test := range tests {
+		req := fakeRequest(test.err)
+		if test.status != 0 {
+			req.HTTPResponse = &http.Response{StatusCode: test.status}
+		}
+		if retry := r.ShouldRetry(req); retry != test.retry {
+			t.Errorf("%s: should retry: %t, not %t", test.name, test.retry, retry)
+		}
+	}
+}
+
+func TestRetryerRetryRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       RetryOptions
+		retryCount int
+		min, max   time.Duration
+	}{
+		{name: "first retry", opts: RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: 20 * time.Second}, retryCount: 0, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "third retry", opts: RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: 20 * time.Second}, retryCount: 2, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "capped", opts: RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}, retryCount: 10, min: 500 * time.Millisecond, max: time.Second},
+		{name: "many retries", opts: RetryOptions{BaseDelay: time.Second, MaxDelay: 20 * time.Second}, retryCount: 100, min: 10 * time.Second, max: 20 * time.Second},
+		{name: "base above max", opts: RetryOptions{BaseDelay: time.Minute, MaxDelay: time.Second}, retryCount: 0, min: 500 * time.Millisecond, max: time.Second},
+		{name: "no delay", opts: RetryOptions{}, retryCount: 3, min: 0, max: 0},
+	}
+
+	for _, test := range tests {
+		r := retryer{RetryOptions: test.opts, service: "retry-test", region: testRegion}
+		for i := 0; i < 100; i++ {
+			req := fakeRequest(awserr.New("Throttling", "Rate exceeded", nil))
+			req.RetryCount = test.retryCount
+			if delay := r.RetryRules(req); delay < test.min || delay > test.max {
+				t.Errorf("%s: delay should be between %s and %s, not %s", test.name, test.min, test.max, delay)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryerRetriesTotal(t *testing.T) {
+	r := retryer{RetryOptions: RetryOptions{MaxRetries: 3}, service: "retries-total-test", region: testRegion}
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{err: awserr.New("Throttling", "Rate exceeded", nil), code: "Throttling"},
+		{err: awserr.New("InternalError", "internal error", nil), code: "InternalError"},
+		{err: errors.New("connection reset"), code: "unknown"},
+	}
+
+	for _, test := range tests {
+		counter := RequestRetriesTotalMetric.WithLabelValues(r.service, r.region, test.code)
+		before := metricValue(t, counter)
+		r.RetryRules(fakeRequest(test.err))
+		if retries := metricValue(t, counter) - before; retries != 1 {
+			t.Errorf("%s: 1 retry should be counted, not %f", test.code, retries)
+		}
+	}
+}
+
+func TestIsThrottle(t *testing.T) {
+	tests := []struct {
+		err      error
+		throttle bool
+	}{
+		{err: awserr.New("Throttling", "Rate exceeded", nil), throttle: true},
+		{err: awserr.New("ThrottlingException", "Rate exceeded", nil), throttle: true},
+		{err: awserr.New("RequestLimitExceeded", "Request limit exceeded", nil), throttle: true},
+		{err: awserr.New("ProvisionedThroughputExceededException", "Throughput exceeded", nil), throttle: true},
+		{err: awserr.New("SlowDown", "Slow down", nil), throttle: true},
+		{err: awserr.New("AccessDenied", "access denied", nil), throttle: false},
+		{err: errors.New("Throttling"), throttle: false},
+		{err: nil, throttle: false},
+	}
+
+	for _, test := range tests {
+		if throttle := isThrottle(test.err); throttle != test.throttle {
+			t.Errorf("%v: should be a throttle: %t, not %t", test.err, test.throttle, throttle)
+		}
+	}
+}
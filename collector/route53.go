@@ -1,13 +1,13 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -18,25 +18,31 @@ var (
 )
 
 var route53Collector = TagsCollector{
+	service:       "route53",
 	name:          prometheus.BuildFQName(namespace, "route53", "tags"),
 	help:          "AWS Route53 tags converted to Prometheus labels.",
 	defaultLabels: []string{"identifier", "resource_type"},
-	lister:        &route53Lister{},
+	global:        true,
+	newLister:     func() tagsLister { return newRoute53Lister(nil) },
 }
 
 type route53Lister struct {
-	region  string
-	session *route53.Route53
+	listerConfig
+	session route53iface.Route53API
 }
 
-func (ro *route53Lister) Initialise(_ string) (err error) {
-	ro.region = "global"
-	sess, err := session.NewSession(&aws.Config{})
-	if err != nil {
-		return
+// newRoute53Lister creates a route53Lister that lists tags with the client.
+// If the client is nil, one is created from the session when the lister is initialised.
+func newRoute53Lister(client route53iface.Route53API) *route53Lister {
+	return &route53Lister{session: client}
+}
+
+func (ro *route53Lister) Initialise(cfg listerConfig) error {
+	ro.listerConfig = cfg
+	if ro.session == nil {
+		ro.session = route53.New(cfg.sess)
 	}
-	ro.session = route53.New(sess)
-	return
+	return nil
 }
 
 func (ro *route53Lister) parseHostedZoneID(id string) string {
@@ -59,35 +65,45 @@ func (ro *route53Lister) groupIntoSlices(ids []*string) [][]*string {
 	return groups
 }
 
-func (ro *route53Lister) List() ([]tags, error) {
+func (ro *route53Lister) List(ctx context.Context) ([]tags, error) {
+
+	hostedzonenames := make(map[string]string)
+	zoneIDs := make([]*string, 0)
+	pages := 0
+	err := ro.session.ListHostedZonesPagesWithContext(ctx, &route53.ListHostedZonesInput{}, func(hostedzones *route53.ListHostedZonesOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
+		for _, zone := range hostedzones.HostedZones {
+			actualID := ro.parseHostedZoneID(*zone.Id)
+			hostedzonenames[actualID] = *zone.Name
+			zoneIDs = append(zoneIDs, &actualID)
+		}
+
+		return ro.nextPage("route53", &pages, lastPage)
+	})
 
-	hostedzones, err := ro.session.ListHostedZones(&route53.ListHostedZonesInput{})
-	RequestTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
 	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
 		RequestErrorTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
 		return []tags{}, err
 	}
 
-	hostedzonenames := make(map[string]string)
-	zoneIDs := make([]*string, 0, len(hostedzones.HostedZones))
-	for _, zone := range hostedzones.HostedZones {
-		actualID := ro.parseHostedZoneID(*zone.Id)
-		hostedzonenames[actualID] = *zone.Name
-		zoneIDs = append(zoneIDs, &actualID)
-	}
+	healthcheckIDs := make([]*string, 0)
+	pages = 0
+	err = ro.session.ListHealthChecksPagesWithContext(ctx, &route53.ListHealthChecksInput{}, func(healthchecks *route53.ListHealthChecksOutput, lastPage bool) bool {
+		RequestTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
+		for _, healthcheck := range healthchecks.HealthChecks {
+			healthcheckIDs = append(healthcheckIDs, healthcheck.Id)
+		}
+
+		return ro.nextPage("route53", &pages, lastPage)
+	})
 
-	healthchecks, err := ro.session.ListHealthChecks(&route53.ListHealthChecksInput{})
-	RequestTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
 	if err != nil {
+		RequestTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
 		RequestErrorTotalMetric.With(prometheus.Labels{"service": "route53", "region": ro.region}).Inc()
 		return []tags{}, err
 	}
 
-	healthcheckIDs := make([]*string, 0, len(healthchecks.HealthChecks))
-	for _, healthcheck := range healthchecks.HealthChecks {
-		healthcheckIDs = append(healthcheckIDs, healthcheck.Id)
-	}
-
 	numReqs := (len(zoneIDs)+len(healthcheckIDs))/10 + 2
 	reqs := make([]*request.Request, 0, numReqs)
 	outs := make([]*route53.ListTagsForResourcesOutput, 0, numReqs)
@@ -104,7 +120,7 @@ func (ro *route53Lister) List() ([]tags, error) {
 		outs = append(outs, out)
 	}
 
-	errs := makeConcurrentRequests(reqs, "route53")
+	errs := makeConcurrentRequests(ctx, reqs, "route53")
 
 	tagsList := make([]tags, 0, numReqs)
 
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+type fakeRoute53 struct {
+	route53iface.Route53API
+	zones        [][]string                // zones are the hosted zone IDs returned by each page of ListHostedZones
+	healthchecks [][]string                // healthchecks are the health check IDs returned by each page of ListHealthChecks
+	tags         map[string][]*route53.Tag // tags are the tags of each resource
+	listErr      error
+}
+
+func (f *fakeRoute53) ListHostedZonesPagesWithContext(ctx aws.Context, in *route53.ListHostedZonesInput, fn func(*route53.ListHostedZonesOutput, bool) bool, opts ...request.Option) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
+	for i, page := range f.zones {
+		out := &route53.ListHostedZonesOutput{}
+		for _, id := range page {
+			out.HostedZones = append(out.HostedZones, &route53.HostedZone{Id: aws.String("/hostedzone/" + id), Name: aws.String(id + ".example.com.")})
+		}
+		if !fn(out, i == len(f.zones)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeRoute53) ListHealthChecksPagesWithContext(ctx aws.Context, in *route53.ListHealthChecksInput, fn func(*route53.ListHealthChecksOutput, bool) bool, opts ...request.Option) error {
+	for i, page := range f.healthchecks {
+		out := &route53.ListHealthChecksOutput{}
+		for _, id := range page {
+			out.HealthChecks = append(out.HealthChecks, &route53.HealthCheck{Id: aws.String(id)})
+		}
+		if !fn(out, i == len(f.healthchecks)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeRoute53) ListTagsForResourcesRequest(in *route53.ListTagsForResourcesInput) (*request.Request, *route53.ListTagsForResourcesOutput) {
+	out := &route53.ListTagsForResourcesOutput{}
+	for _, id := range in.ResourceIds {
+		out.ResourceTagSets = append(out.ResourceTagSets, &route53.ResourceTagSet{ResourceId: id, ResourceType: in.ResourceType, Tags: f.tags[*id]})
+	}
+	return fakeRequest(nil), out
+}
+
+func TestRoute53Lister(t *testing.T) {
+	runListerTests(t, []listerTest{
+		{
+			name: "hosted zones and health checks",
+			lister: newRoute53Lister(&fakeRoute53{
+				zones:        [][]string{{"Z1"}, {"Z2"}},
+				healthchecks: [][]string{{"hc-1"}},
+				tags: map[string][]*route53.Tag{
+					"Z1":   {{Key: aws.String("team"), Value: aws.String("infra")}},
+					"hc-1": {{Key: aws.String("team"), Value: aws.String("sre")}},
+				},
+			}),
+			resources: []string{
+				"identifier=Z1,resource_type=hostedzone,team=infra",
+				"identifier=Z2,resource_type=hostedzone",
+				"identifier=hc-1,resource_type=healthcheck,team=sre",
+			},
+		},
+		{
+			name:   "failed list",
+			lister: newRoute53Lister(&fakeRoute53{listErr: errFake}),
+			err:    true,
+		},
+	})
+}
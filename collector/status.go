@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// collectorDataAgeDesc describes the age of the tags served by a collector
+	collectorDataAgeDesc = prometheus.NewDesc(
+		"aws_tags_collector_data_age_seconds",
+		"Seconds since the tags served by a collector were last listed successfully",
+		[]string{"collector", "region", "account_id"},
+		nil,
+	)
+)
+
+// TargetStatus is the status of listing the tags of a single region and account.
+type TargetStatus struct {
+	Region    string `json:"region"`
+	AccountID string `json:"account_id"`
+	// LastSuccess is when the tags were last listed successfully, nil if they never were.
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// LastError is the error of the last listing, empty if it succeeded.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// CollectorStatus is the status of a collector across all of its targets.
+type CollectorStatus struct {
+	// Ready is true once the tags of every target have been listed successfully at least once.
+	Ready   bool           `json:"ready"`
+	Targets []TargetStatus `json:"targets"`
+}
+
+// record records the result of listing the target's tags.
+func (t *target) record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastErr = err
+	if err == nil {
+		t.lastSuccess = time.Now()
+		t.failingSince = time.Time{}
+	} else if t.failingSince.IsZero() {
+		t.failingSince = time.Now()
+	}
+}
+
+// recordHealth sets the aws_tags_collector_* gauges of the target of service after a listing that took duration and
+// returned resources or failed with err, unless the target has been retired.
+func (t *target) recordHealth(service string, duration time.Duration, resources int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.retired {
+		return
+	}
+
+	labels := prometheus.Labels{"collector": service, "region": t.region, "account_id": t.accountID}
+	CollectorDurationMetric.With(labels).Set(duration.Seconds())
+	if err != nil {
+		CollectorSuccessMetric.With(labels).Set(0)
+		return
+	}
+
+	CollectorSuccessMetric.With(labels).Set(1)
+	CollectorResourcesMetric.With(labels).Set(float64(resources))
+	CollectorLastSuccessMetric.With(labels).Set(float64(time.Now().Unix()))
+}
+
+// retire deletes the aws_tags_collector_* gauges of the target of service once it is no longer collected,
+// so that a listing still in progress cannot set them again.
+func (t *target) retire(service string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retired = true
+
+	labels := prometheus.Labels{"collector": service, "region": t.region, "account_id": t.accountID}
+	CollectorSuccessMetric.Delete(labels)
+	CollectorDurationMetric.Delete(labels)
+	CollectorResourcesMetric.Delete(labels)
+	CollectorLastSuccessMetric.Delete(labels)
+}
+
+// expired reports whether listing the target's tags has been failing for longer than the grace period.
+func (t *target) expired(gracePeriod time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.failingSince.IsZero() && time.Since(t.failingSince) > gracePeriod
+}
+
+// status returns the status of the target and whether its tags have ever been listed successfully.
+func (t *target) status() (TargetStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := TargetStatus{Region: t.region, AccountID: t.accountID}
+	if t.lastErr != nil {
+		s.LastError = t.lastErr.Error()
+	}
+	if t.lastSuccess.IsZero() {
+		return s, false
+	}
+	lastSuccess := t.lastSuccess
+	s.LastSuccess = &lastSuccess
+	return s, true
+}
+
+// Status reports whether the tags of every target of the collector have been listed successfully at least once,
+// along with the status of each target.
+// Collectors that list the tags on every scrape only become ready after their first successful scrape.
+func (tc *TagsCollector) Status() CollectorStatus {
+	status := CollectorStatus{Ready: true, Targets: make([]TargetStatus, 0, len(tc.targets))}
+	for _, t := range tc.targets {
+		s, ok := t.status()
+		status.Ready = status.Ready && ok
+		status.Targets = append(status.Targets, s)
+	}
+	return status
+}
+
+// dataAgeCollector is a prometheus.Collector of the age of the tags of a set of TagsCollectors.
+type dataAgeCollector struct {
+	collectors func() []*TagsCollector
+}
+
+// NewDataAgeCollector returns a prometheus.Collector of the aws_tags_collector_data_age_seconds metric.
+// It reports the age of the tags of every region and account of the collectors returned by collectors,
+// as of the time it is collected. Regions and accounts whose tags have never been listed are not reported.
+func NewDataAgeCollector(collectors func() []*TagsCollector) prometheus.Collector {
+	return dataAgeCollector{collectors: collectors}
+}
+
+// Describe is required to implement the prometheus.Collector interface.
+func (c dataAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collectorDataAgeDesc
+}
+
+// Collect is required to implement the prometheus.Collector interface.
+func (c dataAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for _, tc := range c.collectors() {
+		for _, t := range tc.targets {
+			t.mu.Lock()
+			lastSuccess := t.lastSuccess
+			t.mu.Unlock()
+			if lastSuccess.IsZero() {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(collectorDataAgeDesc, prometheus.GaugeValue, now.Sub(lastSuccess).Seconds(), tc.service, t.region, t.accountID)
+		}
+	}
+}
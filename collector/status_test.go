@@ -0,0 +1,260 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCollectorStatus(t *testing.T) {
+	working := &fakeEC2{pages: [][]*ec2.TagDescription{{ec2Tag("i-1", "instance", "Name", "web")}}}
+	failing := &fakeEC2{err: errFake}
+	tc := ec2Collector
+	tc.targets = []*target{
+		{region: "eu-west-1", accountID: testAccountID, lister: newEC2Lister(working)},
+		{region: "us-east-1", accountID: testAccountID, lister: newEC2Lister(failing)},
+	}
+	for _, target := range tc.targets {
+		if err := target.lister.Initialise(listerConfig{region: target.region, accountID: target.accountID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status := tc.Status()
+	if status.Ready {
+		t.Error("Collector should not be ready before listing any tags")
+	}
+
+	for _, target := range tc.targets {
+		_, _ = tc.list(context.Background(), target)
+	}
+	status = tc.Status()
+	if status.Ready {
+		t.Error("Collector should not be ready while a target has never listed its tags")
+	}
+	if len(status.Targets) != 2 {
+		t.Fatalf("Collector should have 2 targets, not %d", len(status.Targets))
+	}
+	if s := status.Targets[0]; s.LastSuccess == nil || s.LastError != "" {
+		t.Errorf("Target %s should have succeeded, not %+v", s.Region, s)
+	}
+	if s := status.Targets[1]; s.LastSuccess != nil || s.LastError == "" {
+		t.Errorf("Target %s should have failed, not %+v", s.Region, s)
+	}
+
+	failing.err = nil
+	_, _ = tc.list(context.Background(), tc.targets[1])
+	status = tc.Status()
+	if !status.Ready {
+		t.Errorf("Collector should be ready once every target has listed its tags, not %+v", status)
+	}
+
+	// A failure after a success keeps the collector ready, but is reported.
+	failing.err = errFake
+	_, _ = tc.list(context.Background(), tc.targets[1])
+	status = tc.Status()
+	if !status.Ready || status.Targets[1].LastError == "" {
+		t.Errorf("Collector should stay ready and report the last error, not %+v", status)
+	}
+}
+
+func TestStaleGracePeriod(t *testing.T) {
+	client := &fakeEC2{pages: [][]*ec2.TagDescription{{ec2Tag("i-1", "instance", "Name", "web")}}}
+	tc := ec2Collector
+	tc.targets = []*target{{region: testRegion, accountID: testAccountID, lister: newEC2Lister(client)}}
+	tc.labelOpts = labelOptions{collision: CollisionPrefix}
+	tc.gracePeriod = time.Hour
+	if err := tc.targets[0].lister.Initialise(listerConfig{region: testRegion, accountID: testAccountID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if series := collectFrom(t, &tc); len(series) != 1 {
+		t.Fatalf("Collector should emit 1 series, not %d", len(series))
+	}
+
+	client.err = errFake
+	if series := collectFrom(t, &tc); len(series) != 1 {
+		t.Errorf("Collector should keep emitting the last listed series during the grace period, not %d series", len(series))
+	}
+
+	ages := make(chan prometheus.Metric, 1)
+	NewDataAgeCollector(func() []*TagsCollector { return []*TagsCollector{&tc} }).Collect(ages)
+	var pb dto.Metric
+	if err := (<-ages).Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	if age := pb.GetGauge().GetValue(); age < 0 || age > 60 {
+		t.Errorf("Data age should be the seconds since the last successful listing, not %f", age)
+	}
+
+	// Expire the grace period
+	tc.targets[0].failingSince = time.Now().Add(-2 * time.Hour)
+	if series := collectFrom(t, &tc); len(series) != 0 {
+		t.Errorf("Collector should drop the series once the grace period has expired, not emit %d", len(series))
+	}
+
+	client.err = nil
+	if series := collectFrom(t, &tc); len(series) != 1 {
+		t.Errorf("Collector should emit 1 series once listing succeeds again, not %d", len(series))
+	}
+}
+
+func TestDisableHealthMetrics(t *testing.T) {
+	client := &fakeEC2{pages: [][]*ec2.TagDescription{{ec2Tag("i-1", "instance", "Name", "web")}}}
+	for _, disable := range []bool{false, true} {
+		tc := ec2Collector
+		tc.disableHealthMetrics = disable
+		tc.targets = []*target{{region: "health-test-1", accountID: testAccountID, lister: newEC2Lister(client)}}
+		if err := tc.targets[0].lister.Initialise(listerConfig{region: "health-test-1", accountID: testAccountID}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tc.list(context.Background(), tc.targets[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		labels := prometheus.Labels{"collector": "ec2", "region": "health-test-1", "account_id": testAccountID}
+		for name, gauge := range map[string]*prometheus.GaugeVec{
+			"success":      CollectorSuccessMetric,
+			"duration":     CollectorDurationMetric,
+			"resources":    CollectorResourcesMetric,
+			"last success": CollectorLastSuccessMetric,
+		} {
+			if set := gauge.Delete(labels); set == disable {
+				t.Errorf("The %s gauge should only be set when health metrics are enabled, disabled: %t, set: %t", name, disable, set)
+			}
+		}
+	}
+}
+
+// countingLister lists a single resource, or fails with err, counting how many times it is listed.
+type countingLister struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (l *countingLister) Initialise(cfg listerConfig) error {
+	return nil
+}
+
+func (l *countingLister) List(ctx context.Context) ([]tags, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return []tags{{keys: []string{"resource_id", "resource_type", "region", "Name"}, values: []string{"i-1", "instance", testRegion, "web"}}}, nil
+}
+
+func (l *countingLister) listed() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls
+}
+
+// waitFor polls cond until it is true, failing the test if it is not within a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegisterRefresh(t *testing.T) {
+	lister := &countingLister{}
+	tc := ec2Collector
+	tc.newLister = func() tagsLister { return lister }
+	accounts := []Account{{ID: testAccountID, credentials: credentials.NewStaticCredentials("id", "secret", "")}}
+	err := tc.Register([]string{testRegion}, accounts, Options{RefreshInterval: time.Hour, StaleGracePeriod: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Stop()
+
+	waitFor(t, "the first refresh", func() bool { return tc.Status().Ready })
+	for i := 0; i < 3; i++ {
+		if series := collectFrom(t, &tc); len(series) != 1 || series[0]["Name"] != "web" {
+			t.Errorf("Collect should serve the cached tags, not %v", series)
+		}
+	}
+	if calls := lister.listed(); calls != 1 {
+		t.Errorf("Collect should not list the tags, they should be listed once by the refresh, not %d times", calls)
+	}
+
+	// A failed refresh keeps the last good tags
+	lister.mu.Lock()
+	lister.err = errFake
+	lister.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	go tc.refresh(ctx, tc.targets[0], time.Hour)
+	waitFor(t, "the failed refresh", func() bool { return tc.Status().Targets[0].LastError != "" })
+	cancel()
+
+	if series := collectFrom(t, &tc); len(series) != 1 || series[0]["Name"] != "web" {
+		t.Errorf("Collect should serve the last good tags after a failed refresh, not %v", series)
+	}
+	if calls := lister.listed(); calls != 2 {
+		t.Errorf("The tags should only be listed by the refreshes, not %d times", calls)
+	}
+}
+
+func TestCollectorHealthMetrics(t *testing.T) {
+	const region = "health-metrics-1"
+	client := &fakeEC2{pages: [][]*ec2.TagDescription{{ec2Tag("i-1", "instance", "Name", "web"), ec2Tag("vol-1", "volume", "Name", "data")}}}
+	tc := ec2Collector
+	tc.targets = []*target{{region: region, accountID: testAccountID, lister: newEC2Lister(client)}}
+	if err := tc.targets[0].lister.Initialise(listerConfig{region: region, accountID: testAccountID}); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := []string{"ec2", region, testAccountID}
+	success := CollectorSuccessMetric.WithLabelValues(labels...)
+	duration := CollectorDurationMetric.WithLabelValues(labels...)
+	resources := CollectorResourcesMetric.WithLabelValues(labels...)
+	lastSuccess := CollectorLastSuccessMetric.WithLabelValues(labels...)
+
+	before := time.Now().Unix()
+	if _, err := tc.list(context.Background(), tc.targets[0]); err != nil {
+		t.Fatal(err)
+	}
+	if v := metricValue(t, success); v != 1 {
+		t.Errorf("Success should be 1 after a successful listing, not %f", v)
+	}
+	if v := metricValue(t, duration); v < 0 || v > 5 {
+		t.Errorf("Duration should be the seconds the listing took, not %f", v)
+	}
+	if v := metricValue(t, resources); v != 2 {
+		t.Errorf("Resources should be 2 after listing 2 resources, not %f", v)
+	}
+	succeeded := metricValue(t, lastSuccess)
+	if succeeded < float64(before) || succeeded > float64(time.Now().Unix()) {
+		t.Errorf("Last success should be the time of the listing, not %f", succeeded)
+	}
+
+	client.err = errFake
+	if _, err := tc.list(context.Background(), tc.targets[0]); err == nil {
+		t.Fatal("Listing should fail")
+	}
+	if v := metricValue(t, success); v != 0 {
+		t.Errorf("Success should be 0 after a failed listing, not %f", v)
+	}
+	if v := metricValue(t, duration); v < 0 || v > 5 {
+		t.Errorf("Duration should be the seconds the failed listing took, not %f", v)
+	}
+	if v := metricValue(t, resources); v != 2 {
+		t.Errorf("Resources should keep the count of the last successful listing, not %f", v)
+	}
+	if v := metricValue(t, lastSuccess); v != succeeded {
+		t.Errorf("Last success should keep the time of the last successful listing %f, not %f", succeeded, v)
+	}
+}
@@ -1,17 +1,123 @@
 package collector
 
 import (
+	"context"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
 )
 
-func makeConcurrentRequests(reqs []*request.Request, service string) []error {
+// requestLimiter bounds the concurrency and rate of every request sent to AWS by the collectors.
+type requestLimiter struct {
+	global   chan struct{}            // global is a semaphore shared by every service (nil is unlimited)
+	services map[string]chan struct{} // services are the semaphores of each service (missing is unlimited)
+	rate     *rate.Limiter            // rate is a token bucket shared by every service (nil is unlimited)
+}
+
+var limiter = &requestLimiter{}
+
+// SetRequestLimits limits the requests that collectors make concurrently to AWS.
+// global bounds the concurrent requests across all collectors and perService bounds them per collector.
+// requestsPerSecond and burst configure a token bucket shared by all collectors.
+// Limits that are not positive are unlimited. It must be called before any collector is registered.
+func SetRequestLimits(global int, perService map[string]int, requestsPerSecond float64, burst int) {
+	l := &requestLimiter{services: make(map[string]chan struct{}, len(perService))}
+	if global > 0 {
+		l.global = make(chan struct{}, global)
+	}
+
+	for service, n := range perService {
+		if n > 0 {
+			l.services[service] = make(chan struct{}, n)
+		}
+	}
+
+	if requestsPerSecond > 0 {
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	}
+
+	limiter = l
+}
+
+// acquire blocks until a request for service may be sent, returning a function that releases its slots.
+// It returns an error, having released any slots it acquired, if the context is done first.
+func (l *requestLimiter) acquire(ctx context.Context, service string) (release func(), err error) {
+	start := time.Now()
+	defer func() {
+		RequestQueueSecondsMetric.With(prometheus.Labels{"service": service}).Observe(time.Since(start).Seconds())
+	}()
+
+	sem := l.services[service]
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if l.global != nil {
+		select {
+		case l.global <- struct{}{}:
+		case <-ctx.Done():
+			if sem != nil {
+				<-sem
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	release = func() {
+		if l.global != nil {
+			<-l.global
+		}
+		if sem != nil {
+			<-sem
+		}
+	}
+
+	if l.rate != nil {
+		if err = l.rate.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// limitedSendHandler returns a request handler that sends requests for service with send once they are within
+// the limits set by SetRequestLimits. It replaces the SDK's send handler so that every attempt of every request,
+// including retries, waits for the limits, and only holds its slots while it is being sent.
+func limitedSendHandler(service string, send func(*request.Request)) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "aws_tags_exporter.LimitedSendHandler",
+		Fn: func(r *request.Request) {
+			release, err := limiter.acquire(r.Context(), service)
+			if err != nil {
+				r.Error = awserr.New(request.CanceledErrorCode, "request context canceled while waiting for the request limits", err)
+				return
+			}
+			defer release()
+			send(r)
+		},
+	}
+}
+
+// makeConcurrentRequests sends the requests concurrently. Each request waits for the limits set by SetRequestLimits
+// when it is sent. The requests are cancelled when the context is done.
+func makeConcurrentRequests(ctx context.Context, reqs []*request.Request, service string) []error {
 	var wg sync.WaitGroup
 	var errs = make([]error, len(reqs))
 	glog.V(4).Infof("Collecting %s", service)
@@ -19,6 +125,7 @@ func makeConcurrentRequests(reqs []*request.Request, service string) []error {
 	for i := range reqs {
 		go func(i int, req *request.Request) {
 			defer wg.Done()
+			req.SetContext(ctx)
 			errs[i] = req.Send()
 		}(i, reqs[i])
 	}
@@ -26,8 +133,25 @@ func makeConcurrentRequests(reqs []*request.Request, service string) []error {
 	return errs
 }
 
-func getAccountID() (string, error) {
-	st := sts.New(session.New(&aws.Config{}))
+// nextPage records that a page was fetched for service and reports whether the next page should be fetched.
+// Pagination stops after the last page or once maxPages pages have been fetched.
+func (cfg listerConfig) nextPage(service string, pages *int, lastPage bool) bool {
+	*pages++
+	PagesTotalMetric.With(prometheus.Labels{"service": service, "region": cfg.region}).Inc()
+	if lastPage {
+		return false
+	}
+
+	if cfg.maxPages > 0 && *pages >= cfg.maxPages {
+		glog.Warningf("Stopped paginating %s in %s after %d pages", service, cfg.region, *pages)
+		return false
+	}
+
+	return true
+}
+
+func getAccountID(sess *session.Session) (string, error) {
+	st := sts.New(sess)
 	out, err := st.GetCallerIdentity(&sts.GetCallerIdentityInput{})
 
 	RequestTotalMetric.With(prometheus.Labels{"service": "sts", "region": "global"}).Inc()
@@ -39,6 +163,16 @@ func getAccountID() (string, error) {
 	return *out.Account, nil
 }
 
+// sanitizeLabelName converts s to a valid Prometheus label name by replacing invalid characters with "_"
+// and prefixing it with "_" if it does not start with a letter or "_".
+// Leading underscores are collapsed to a single "_", since names starting with "__" are reserved.
 func sanitizeLabelName(s string) string {
-	return invalidLabelCharRE.ReplaceAllString(s, "_")
+	s = invalidLabelCharRE.ReplaceAllString(s, "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	if strings.HasPrefix(s, "__") {
+		s = "_" + strings.TrimLeft(s, "_")
+	}
+	return s
 }
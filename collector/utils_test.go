@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// queueSamples returns how many requests of service have observed their time waiting for the request limits.
+func queueSamples(t *testing.T, service string) uint64 {
+	var m dto.Metric
+	if err := RequestQueueSecondsMetric.WithLabelValues(service).(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+// setRequestLimits sets the request limits for the duration of the test.
+func setRequestLimits(t *testing.T, global int, perService map[string]int) {
+	previous := limiter
+	SetRequestLimits(global, perService, 0, 0)
+	t.Cleanup(func() { limiter = previous })
+}
+
+func TestRequestLimiterAcquire(t *testing.T) {
+	const service = "limiter-test"
+	setRequestLimits(t, 3, map[string]int{service: 2})
+	samples := queueSamples(t, service)
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := limiter.acquire(context.Background(), service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+	if n := queueSamples(t, service) - samples; n != 2 {
+		t.Errorf("2 queue times should be observed, not %d", n)
+	}
+
+	// The service is at its limit, so further requests wait until their context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.acquire(ctx, service); err != context.Canceled {
+		t.Errorf("Acquiring beyond the service limit should fail with %v, not %v", context.Canceled, err)
+	}
+	if n := len(limiter.services[service]); n != 2 {
+		t.Errorf("The service should have 2 slots in use, not %d", n)
+	}
+
+	// Other services are only bound by the global limit, which a cancelled request must not keep.
+	release, err := limiter.acquire(context.Background(), "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.acquire(ctx, "other"); err != context.Canceled {
+		t.Errorf("Acquiring beyond the global limit should fail with %v, not %v", context.Canceled, err)
+	}
+	if n := len(limiter.global); n != 3 {
+		t.Errorf("3 global slots should be in use, not %d", n)
+	}
+	release()
+
+	for _, release := range releases {
+		release()
+	}
+	if len(limiter.global) != 0 || len(limiter.services[service]) != 0 {
+		t.Errorf("Every slot should be released, not %d global and %d %s slots", len(limiter.global), len(limiter.services[service]), service)
+	}
+	if n := queueSamples(t, service) - samples; n != 3 {
+		t.Errorf("Cancelled requests should observe their queue time too, 3 should be observed, not %d", n)
+	}
+}
+
+func TestSessionLimitsRequests(t *testing.T) {
+	const service = "session-test"
+	setRequestLimits(t, 0, map[string]int{service: 1})
+
+	a := Account{ID: testAccountID, credentials: credentials.NewStaticCredentials("id", "secret", "")}
+	sess, err := a.session(testRegion, retryer{service: service, region: testRegion})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := limiter.acquire(context.Background(), service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	samples := queueSamples(t, service)
+	req := fakeRequest(nil)
+	req.SetContext(ctx)
+	sess.Handlers.Send.Run(req)
+	if n := queueSamples(t, service) - samples; n != 1 {
+		t.Errorf("Requests sent with the session should wait for the request limits, %d waited", n)
+	}
+	if aerr, ok := req.Error.(awserr.Error); !ok || aerr.Code() != request.CanceledErrorCode {
+		t.Errorf("Requests sent beyond the limits should be cancelled with their context, not %v", req.Error)
+	}
+	if req.HTTPResponse != nil {
+		t.Error("Requests sent beyond the limits should not reach AWS")
+	}
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
+	"gopkg.in/yaml.v2"
+)
+
+// config holds every setting of the exporter.
+// It is populated by the flags and, if one is specified, the configuration file.
+type config struct {
+	Host            string
+	Port            int
+	TelemetryPort   int
+	TimeoutOffset   time.Duration
+	ShutdownTimeout time.Duration
+	SinglePort      bool
+	MetricsPath     string
+	TelemetryPath   string
+	WebConfigFile   string
+
+	Regions  regionList
+	Roles    roleList
+	Includes collectorSet
+	Excludes collectorSet
+
+	RefreshInterval  time.Duration
+	RefreshIntervals collectorDurations
+	PageLimit        int
+	PageLimits       collectorInts
+	Timeout          time.Duration
+	StaleGracePeriod time.Duration
+	ResourceTypes    stringList
+
+	LabelCollision  acollector.CollisionPolicy
+	TagPrefix       bool
+	TagInclude      tagRules
+	TagExclude      tagRules
+	TagIncludes     collectorTagRules
+	TagExcludes     collectorTagRules
+	DropAWSReserved bool
+	TagSchema       stringList
+	TagSchemas      collectorTagKeys
+
+	MaxConcurrency    int
+	MaxConcurrencies  collectorInts
+	RequestsPerSecond float64
+	RequestsBurst     int
+	MaxRetries        int
+	CollectorRetries  collectorInts
+	RetryBaseDelay    time.Duration
+	RetryMaxDelay     time.Duration
+}
+
+// newConfig returns the default configuration.
+func newConfig() *config {
+	return &config{
+		Host:             "0.0.0.0",
+		Port:             60020,
+		TelemetryPort:    60021,
+		TimeoutOffset:    500 * time.Millisecond,
+		ShutdownTimeout:  30 * time.Second,
+		MetricsPath:      "/metrics",
+		TelemetryPath:    "/metrics",
+		Includes:         make(collectorSet),
+		Excludes:         make(collectorSet),
+		RefreshInterval:  5 * time.Minute,
+		StaleGracePeriod: 15 * time.Minute,
+		RefreshIntervals: make(collectorDurations),
+		PageLimits:       make(collectorInts),
+		LabelCollision:   acollector.CollisionPrefix,
+		TagIncludes:      make(collectorTagRules),
+		TagExcludes:      make(collectorTagRules),
+		TagSchemas:       make(collectorTagKeys),
+		MaxConcurrency:   20,
+		MaxConcurrencies: make(collectorInts),
+		RequestsBurst:    10,
+		MaxRetries:       5,
+		CollectorRetries: make(collectorInts),
+		RetryBaseDelay:   100 * time.Millisecond,
+		RetryMaxDelay:    20 * time.Second,
+	}
+}
+
+// replaceValue is a repeatable flag.Value whose values replace, rather than add to, the value it had before the flags
+// were parsed (e.g. the regions in the configuration file).
+type replaceValue struct {
+	flag.Value
+	reset func()
+	set   bool
+}
+
+func (rv *replaceValue) String() string {
+	if rv.Value == nil {
+		return ""
+	}
+	return rv.Value.String()
+}
+
+func (rv *replaceValue) Set(value string) error {
+	if !rv.set {
+		rv.reset()
+		rv.set = true
+	}
+	return rv.Value.Set(value)
+}
+
+// registerFlags defines a flag for every setting, using the current value of the setting as its default.
+func (c *config) registerFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.TelemetryPort, "web.telemetry-port", c.TelemetryPort, "Port number to listen on for telemetry")
+	fs.IntVar(&c.Port, "web.port", c.Port, "Port number to listen on for metrics")
+	fs.StringVar(&c.Host, "web.host", c.Host, "Port number to listen on, default is 0.0.0.0")
+	fs.DurationVar(&c.TimeoutOffset, "web.timeout-offset", c.TimeoutOffset, "Offset subtracted from Prometheus's scrape timeout to leave time to send the response")
+	fs.DurationVar(&c.ShutdownTimeout, "web.shutdown-timeout", c.ShutdownTimeout, "Maximum time to wait for in-flight requests to complete when shutting down")
+	fs.BoolVar(&c.SinglePort, "web.single-port", c.SinglePort, "Serve the telemetry on web.port instead of web.telemetry-port")
+	fs.StringVar(&c.MetricsPath, "web.metrics-path", c.MetricsPath, "Path under which to expose the tag metrics")
+	fs.StringVar(&c.TelemetryPath, "web.telemetry-path", c.TelemetryPath, "Path under which to expose the telemetry, merged with the tag metrics if it is web.metrics-path in single port mode")
+	fs.StringVar(&c.WebConfigFile, "web.config.file", c.WebConfigFile, "Path to a web configuration file enabling TLS and basic authentication, in the Prometheus exporter toolkit format")
+	fs.Var(&replaceValue{Value: &c.Regions, reset: func() { c.Regions = nil }}, "aws.region", "Comma-separated list of AWS regions to query, or all")
+	fs.Var(&replaceValue{Value: &c.Roles, reset: func() { c.Roles = nil }}, "aws.role", "IAM role to assume, as <arn>[,external_id=<id>][,session_name=<name>][,name=<account name>] (may be repeated)")
+	fs.DurationVar(&c.RefreshInterval, "collector.refresh-interval", c.RefreshInterval, "Interval at which collectors refresh their tags in the background, 0 lists tags on every scrape")
+	fs.Var(&c.RefreshIntervals, "collector.refresh-intervals", "Comma-separated list of <collector>=<duration> overrides of collector.refresh-interval")
+	fs.IntVar(&c.PageLimit, "collector.page-limit", c.PageLimit, "Maximum number of pages fetched by each paginated AWS request, 0 is unlimited")
+	fs.Var(&c.PageLimits, "collector.page-limits", "Comma-separated list of <collector>=<pages> overrides of collector.page-limit")
+	fs.Var(&c.LabelCollision, "tags.label-collision", "How to handle tags whose keys collide with another label: prefix, drop or merge")
+	fs.BoolVar(&c.TagPrefix, "tags.prefix", c.TagPrefix, "Prefix every tag label name with tag_")
+	fs.Var(&replaceValue{Value: &c.TagInclude, reset: func() { c.TagInclude = nil }}, "tags.include", "Tag key to include, or /regex/ matching the keys to include (may be repeated)")
+	fs.Var(&replaceValue{Value: &c.TagExclude, reset: func() { c.TagExclude = nil }}, "tags.exclude", "Tag key to exclude, or /regex/ matching the keys to exclude (may be repeated)")
+	fs.Var(&c.TagIncludes, "collector.tags.include", "<collector>=<rule> tag include rule for a single collector (may be repeated)")
+	fs.Var(&c.TagExcludes, "collector.tags.exclude", "<collector>=<rule> tag exclude rule for a single collector (may be repeated)")
+	fs.Var(&replaceValue{Value: &c.TagSchema, reset: func() { c.TagSchema = nil }}, "tags.schema", "Comma-separated list of tag keys that label every series, with empty values for missing tags; other tags are dropped")
+	fs.Var(&c.TagSchemas, "collector.tags.schema", "<collector>=<key>[,<key>...] override of tags.schema for a single collector (may be repeated)")
+	fs.IntVar(&c.MaxConcurrency, "aws.max-concurrency", c.MaxConcurrency, "Maximum number of concurrent AWS requests across all collectors, 0 is unlimited")
+	fs.Var(&c.MaxConcurrencies, "collector.max-concurrency", "Comma-separated list of <collector>=<requests> limits on concurrent AWS requests per collector")
+	fs.Float64Var(&c.RequestsPerSecond, "aws.requests-per-second", c.RequestsPerSecond, "Maximum number of concurrent AWS requests sent per second across all collectors, 0 is unlimited")
+	fs.IntVar(&c.RequestsBurst, "aws.requests-burst", c.RequestsBurst, "Maximum burst of AWS requests above aws.requests-per-second")
+	fs.IntVar(&c.MaxRetries, "aws.max-retries", c.MaxRetries, "Maximum number of times a throttled or transiently failing AWS request is retried")
+	fs.Var(&c.CollectorRetries, "collector.max-retries", "Comma-separated list of <collector>=<retries> overrides of aws.max-retries")
+	fs.DurationVar(&c.RetryBaseDelay, "aws.retry-base-delay", c.RetryBaseDelay, "Delay before the first retry of an AWS request, doubled on every retry")
+	fs.DurationVar(&c.RetryMaxDelay, "aws.retry-max-delay", c.RetryMaxDelay, "Maximum delay between retries of an AWS request")
+	fs.DurationVar(&c.Timeout, "collector.timeout", c.Timeout, "Maximum time to list the tags of a collector in a single region and account, 0 is unbounded")
+	fs.DurationVar(&c.StaleGracePeriod, "collector.stale-grace-period", c.StaleGracePeriod, "How long the last successfully listed tags are served after listing starts failing, 0 drops them immediately")
+	fs.Var(&replaceValue{Value: &c.ResourceTypes, reset: func() { c.ResourceTypes = nil }}, "resourcegroupstaggingapi.resource-types", "Comma-separated list of resource types (e.g. ec2:instance,s3) listed by the resourcegroupstaggingapi collector, empty lists every type")
+	fs.BoolVar(&c.DropAWSReserved, "tags.drop-aws-reserved", c.DropAWSReserved, "Drop the tags reserved for use by AWS (keys starting with aws:)")
+	fs.Var(&replaceValue{Value: &c.Includes, reset: func() { c.Includes = make(collectorSet) }}, "include", "Comma-seperated list of collectors to include")
+	fs.Var(&replaceValue{Value: &c.Excludes, reset: func() { c.Excludes = make(collectorSet) }}, "exclude", "Comma-separated list to exclude from all available collectors")
+}
+
+// options returns the acollector.Options for the named collector.
+func (c *config) options(collector string) acollector.Options {
+	schema, ok := c.TagSchemas[collector]
+	if !ok {
+		schema = c.TagSchema
+	}
+
+	return acollector.Options{
+		RefreshInterval: c.RefreshIntervals.get(collector, c.RefreshInterval),
+		PageLimit:       c.PageLimits.get(collector, c.PageLimit),
+		LabelCollision:  c.LabelCollision,
+		TagPrefix:       c.TagPrefix,
+		TagFilter: acollector.TagFilter{
+			Include:         c.TagInclude,
+			Exclude:         c.TagExclude,
+			DropAWSReserved: c.DropAWSReserved,
+		}.Merge(acollector.TagFilter{
+			Include: c.TagIncludes[collector],
+			Exclude: c.TagExcludes[collector],
+		}),
+		TagSchema: schema,
+		Retry: acollector.RetryOptions{
+			MaxRetries: c.CollectorRetries.get(collector, c.MaxRetries),
+			BaseDelay:  c.RetryBaseDelay,
+			MaxDelay:   c.RetryMaxDelay,
+		},
+		Timeout:          c.Timeout,
+		StaleGracePeriod: c.StaleGracePeriod,
+		ResourceTypes:    c.ResourceTypes,
+	}
+}
+
+// fileConfig is the schema of the configuration file.
+// Settings that are omitted keep their default values.
+type fileConfig struct {
+	Regions          []string                   `yaml:"regions"`
+	Roles            []roleConfig               `yaml:"roles"`
+	Include          []string                   `yaml:"include"`
+	Exclude          []string                   `yaml:"exclude"`
+	RefreshInterval  *time.Duration             `yaml:"refresh_interval"`
+	PageLimit        *int                       `yaml:"page_limit"`
+	Timeout          *time.Duration             `yaml:"timeout"`
+	StaleGracePeriod *time.Duration             `yaml:"stale_grace_period"`
+	Tags             tagsConfig                 `yaml:"tags"`
+	AWS              awsConfig                  `yaml:"aws"`
+	Web              webConfig                  `yaml:"web"`
+	Collectors       map[string]collectorConfig `yaml:"collectors"`
+}
+
+type roleConfig struct {
+	ARN         string `yaml:"arn"`
+	ExternalID  string `yaml:"external_id"`
+	SessionName string `yaml:"session_name"`
+	Name        string `yaml:"name"`
+}
+
+type tagsConfig struct {
+	Prefix          *bool    `yaml:"prefix"`
+	LabelCollision  string   `yaml:"label_collision"`
+	Include         []string `yaml:"include"`
+	Exclude         []string `yaml:"exclude"`
+	DropAWSReserved *bool    `yaml:"drop_aws_reserved"`
+	Schema          []string `yaml:"schema"`
+}
+
+type awsConfig struct {
+	MaxConcurrency    *int           `yaml:"max_concurrency"`
+	RequestsPerSecond *float64       `yaml:"requests_per_second"`
+	RequestsBurst     *int           `yaml:"requests_burst"`
+	MaxRetries        *int           `yaml:"max_retries"`
+	RetryBaseDelay    *time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay     *time.Duration `yaml:"retry_max_delay"`
+}
+
+type webConfig struct {
+	Host            string         `yaml:"host"`
+	Port            *int           `yaml:"port"`
+	TelemetryPort   *int           `yaml:"telemetry_port"`
+	TimeoutOffset   *time.Duration `yaml:"timeout_offset"`
+	ShutdownTimeout *time.Duration `yaml:"shutdown_timeout"`
+	SinglePort      *bool          `yaml:"single_port"`
+	MetricsPath     string         `yaml:"metrics_path"`
+	TelemetryPath   string         `yaml:"telemetry_path"`
+	ConfigFile      string         `yaml:"config_file"`
+}
+
+// collectorConfig overrides the settings of a single collector.
+type collectorConfig struct {
+	RefreshInterval *time.Duration `yaml:"refresh_interval"`
+	PageLimit       *int           `yaml:"page_limit"`
+	MaxConcurrency  *int           `yaml:"max_concurrency"`
+	MaxRetries      *int           `yaml:"max_retries"`
+	ResourceTypes   []string       `yaml:"resource_types"`
+	Tags            struct {
+		Include []string `yaml:"include"`
+		Exclude []string `yaml:"exclude"`
+		Schema  []string `yaml:"schema"`
+	} `yaml:"tags"`
+}
+
+// validatePaths returns an error if the metrics or telemetry paths are not absolute, or are reserved
+// for the index page or the /-/ endpoints.
+func (c *config) validatePaths() error {
+	for name, path := range map[string]string{"metrics": c.MetricsPath, "telemetry": c.TelemetryPath} {
+		if !strings.HasPrefix(path, "/") || path == "/" || strings.HasPrefix(path, "/-/") {
+			return fmt.Errorf("invalid %s path %q: it must start with / and must not be / or start with /-/", name, path)
+		}
+	}
+	return nil
+}
+
+// validate returns an error if any setting is invalid: a negative duration or limit, a malformed region,
+// an unknown collector or a reserved path.
+func (c *config) validate() error {
+	if err := c.validatePaths(); err != nil {
+		return err
+	}
+
+	for _, r := range c.Regions {
+		if r != "all" && !regionRE.MatchString(r) {
+			return fmt.Errorf("invalid region %q", r)
+		}
+	}
+	for name := range c.Includes {
+		if err := validCollector(name); err != nil {
+			return fmt.Errorf("include: %v", err)
+		}
+	}
+	for name := range c.Excludes {
+		if err := validCollector(name); err != nil {
+			return fmt.Errorf("exclude: %v", err)
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"web.timeout-offset", c.TimeoutOffset},
+		{"web.shutdown-timeout", c.ShutdownTimeout},
+		{"collector.refresh-interval", c.RefreshInterval},
+		{"collector.timeout", c.Timeout},
+		{"collector.stale-grace-period", c.StaleGracePeriod},
+		{"aws.retry-base-delay", c.RetryBaseDelay},
+		{"aws.retry-max-delay", c.RetryMaxDelay},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return fmt.Errorf("%s must not be negative, not %s", d.name, d.value)
+		}
+	}
+	for name, d := range c.RefreshIntervals {
+		if err := validCollector(name); err != nil {
+			return fmt.Errorf("collector.refresh-intervals: %v", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("collector.refresh-intervals: %s must not be negative, not %s", name, d)
+		}
+	}
+
+	ints := []struct {
+		name  string
+		value int
+	}{
+		{"collector.page-limit", c.PageLimit},
+		{"aws.max-concurrency", c.MaxConcurrency},
+		{"aws.requests-burst", c.RequestsBurst},
+		{"aws.max-retries", c.MaxRetries},
+	}
+	for _, i := range ints {
+		if i.value < 0 {
+			return fmt.Errorf("%s must not be negative, not %d", i.name, i.value)
+		}
+	}
+	if c.RequestsPerSecond < 0 {
+		return fmt.Errorf("aws.requests-per-second must not be negative, not %g", c.RequestsPerSecond)
+	}
+	perCollector := []struct {
+		name   string
+		values collectorInts
+	}{
+		{"collector.page-limits", c.PageLimits},
+		{"collector.max-concurrency", c.MaxConcurrencies},
+		{"collector.max-retries", c.CollectorRetries},
+	}
+	for _, pc := range perCollector {
+		for name, i := range pc.values {
+			if err := validCollector(name); err != nil {
+				return fmt.Errorf("%s: %v", pc.name, err)
+			}
+			if i < 0 {
+				return fmt.Errorf("%s: %s must not be negative, not %d", pc.name, name, i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validCollector returns an error if there is no collector with the name.
+func validCollector(name string) error {
+	if _, ok := acollector.AvailableCollectors[name]; !ok {
+		return fmt.Errorf("unknown collector %q", name)
+	}
+	return nil
+}
+
+// setRules parses the tag rules and adds them to rules.
+func setRules(rules *tagRules, values []string) error {
+	for _, v := range values {
+		if err := rules.Set(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply validates the configuration file and sets its settings in c.
+func (fc fileConfig) apply(c *config) error {
+	if fc.Regions != nil {
+		c.Regions = fc.Regions
+	}
+	for i, r := range fc.Roles {
+		if r.ARN == "" {
+			return fmt.Errorf("roles[%d]: arn is required", i)
+		}
+		c.Roles = append(c.Roles, acollector.Role{ARN: r.ARN, ExternalID: r.ExternalID, SessionName: r.SessionName, Name: r.Name})
+	}
+
+	for _, name := range fc.Include {
+		if err := validCollector(name); err != nil {
+			return fmt.Errorf("include: %v", err)
+		}
+		c.Includes[name] = struct{}{}
+	}
+	for _, name := range fc.Exclude {
+		if err := validCollector(name); err != nil {
+			return fmt.Errorf("exclude: %v", err)
+		}
+		c.Excludes[name] = struct{}{}
+	}
+
+	if fc.RefreshInterval != nil {
+		c.RefreshInterval = *fc.RefreshInterval
+	}
+	if fc.PageLimit != nil {
+		c.PageLimit = *fc.PageLimit
+	}
+	if fc.Timeout != nil {
+		c.Timeout = *fc.Timeout
+	}
+	if fc.StaleGracePeriod != nil {
+		c.StaleGracePeriod = *fc.StaleGracePeriod
+	}
+
+	if fc.Tags.Prefix != nil {
+		c.TagPrefix = *fc.Tags.Prefix
+	}
+	if fc.Tags.LabelCollision != "" {
+		if err := c.LabelCollision.Set(fc.Tags.LabelCollision); err != nil {
+			return fmt.Errorf("tags.label_collision: %v", err)
+		}
+	}
+	if err := setRules(&c.TagInclude, fc.Tags.Include); err != nil {
+		return fmt.Errorf("tags.include: %v", err)
+	}
+	if err := setRules(&c.TagExclude, fc.Tags.Exclude); err != nil {
+		return fmt.Errorf("tags.exclude: %v", err)
+	}
+	if fc.Tags.DropAWSReserved != nil {
+		c.DropAWSReserved = *fc.Tags.DropAWSReserved
+	}
+	if fc.Tags.Schema != nil {
+		c.TagSchema = fc.Tags.Schema
+	}
+
+	if fc.AWS.MaxConcurrency != nil {
+		c.MaxConcurrency = *fc.AWS.MaxConcurrency
+	}
+	if fc.AWS.RequestsPerSecond != nil {
+		c.RequestsPerSecond = *fc.AWS.RequestsPerSecond
+	}
+	if fc.AWS.RequestsBurst != nil {
+		c.RequestsBurst = *fc.AWS.RequestsBurst
+	}
+	if fc.AWS.MaxRetries != nil {
+		c.MaxRetries = *fc.AWS.MaxRetries
+	}
+	if fc.AWS.RetryBaseDelay != nil {
+		c.RetryBaseDelay = *fc.AWS.RetryBaseDelay
+	}
+	if fc.AWS.RetryMaxDelay != nil {
+		c.RetryMaxDelay = *fc.AWS.RetryMaxDelay
+	}
+
+	if fc.Web.Host != "" {
+		c.Host = fc.Web.Host
+	}
+	if fc.Web.Port != nil {
+		c.Port = *fc.Web.Port
+	}
+	if fc.Web.TelemetryPort != nil {
+		c.TelemetryPort = *fc.Web.TelemetryPort
+	}
+	if fc.Web.TimeoutOffset != nil {
+		c.TimeoutOffset = *fc.Web.TimeoutOffset
+	}
+	if fc.Web.ShutdownTimeout != nil {
+		c.ShutdownTimeout = *fc.Web.ShutdownTimeout
+	}
+	if fc.Web.SinglePort != nil {
+		c.SinglePort = *fc.Web.SinglePort
+	}
+	if fc.Web.MetricsPath != "" {
+		c.MetricsPath = fc.Web.MetricsPath
+	}
+	if fc.Web.TelemetryPath != "" {
+		c.TelemetryPath = fc.Web.TelemetryPath
+	}
+	if fc.Web.ConfigFile != "" {
+		c.WebConfigFile = fc.Web.ConfigFile
+	}
+
+	for name, cc := range fc.Collectors {
+		if err := validCollector(name); err != nil {
+			return fmt.Errorf("collectors: %v", err)
+		}
+
+		if cc.RefreshInterval != nil {
+			c.RefreshIntervals[name] = *cc.RefreshInterval
+		}
+		if cc.PageLimit != nil {
+			c.PageLimits[name] = *cc.PageLimit
+		}
+		if cc.MaxConcurrency != nil {
+			c.MaxConcurrencies[name] = *cc.MaxConcurrency
+		}
+		if cc.MaxRetries != nil {
+			c.CollectorRetries[name] = *cc.MaxRetries
+		}
+		if cc.ResourceTypes != nil {
+			if name != "resourcegroupstaggingapi" {
+				return fmt.Errorf("collectors.%s.resource_types: only the resourcegroupstaggingapi collector lists resource types", name)
+			}
+			c.ResourceTypes = cc.ResourceTypes
+		}
+
+		include, exclude := c.TagIncludes[name], c.TagExcludes[name]
+		if err := setRules(&include, cc.Tags.Include); err != nil {
+			return fmt.Errorf("collectors.%s.tags.include: %v", name, err)
+		}
+		if err := setRules(&exclude, cc.Tags.Exclude); err != nil {
+			return fmt.Errorf("collectors.%s.tags.exclude: %v", name, err)
+		}
+		if include != nil {
+			c.TagIncludes[name] = include
+		}
+		if exclude != nil {
+			c.TagExcludes[name] = exclude
+		}
+		if cc.Tags.Schema != nil {
+			c.TagSchemas[name] = cc.Tags.Schema
+		}
+	}
+
+	return nil
+}
+
+// loadConfig loads the configuration file, if path is not empty, and then parses the command line arguments,
+// so that the flags that are set override the settings in the file. The resulting configuration is validated.
+// Repeatable flags replace the lists in the file, and per-collector flags replace the settings of their collectors.
+func loadConfig(path string, args []string) (*config, error) {
+	c := newConfig()
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var fc fileConfig
+		if err := yaml.UnmarshalStrict(b, &fc); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+		if err := fc.apply(c); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+		}
+	}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	c.registerFlags(fs)
+	// The other flags (e.g. glog's) are parsed again into the values they were already set to.
+	flag.VisitAll(func(f *flag.Flag) {
+		if fs.Lookup(f.Name) == nil {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
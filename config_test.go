@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+regions: [eu-west-1, us-east-1]
+roles:
+  - arn: arn:aws:iam::123456789012:role/tags-reader
+    external_id: secret
+include: [ec2, rds]
+refresh_interval: 10m
+tags:
+  prefix: true
+  include: [team, /cost-.*/]
+collectors:
+  ec2:
+    refresh_interval: 1m
+    tags:
+      exclude: [Name]
+web:
+  port: 9100
+`
+
+// writeConfig writes the configuration to a temporary file and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "aws_tags_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	cfg, err := loadConfig(path, []string{"-aws.region=ap-south-1", "-collector.refresh-intervals=rds=2h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (regionList{"ap-south-1"}); !reflect.DeepEqual(cfg.Regions, want) {
+		t.Errorf("Regions should be %v, not %v", want, cfg.Regions)
+	}
+	if len(cfg.Roles) != 1 || cfg.Roles[0].ExternalID != "secret" {
+		t.Errorf("Roles should be loaded from the file, not %v", cfg.Roles)
+	}
+	if _, ok := cfg.Includes["rds"]; !ok || len(cfg.Includes) != 2 {
+		t.Errorf("Includes should be ec2,rds, not %s", cfg.Includes.String())
+	}
+	if cfg.Port != 9100 || cfg.TelemetryPort != 60021 {
+		t.Errorf("Ports should be 9100 and 60021, not %d and %d", cfg.Port, cfg.TelemetryPort)
+	}
+
+	ec2 := cfg.options("ec2")
+	if ec2.RefreshInterval != time.Minute || !ec2.TagPrefix || len(ec2.TagFilter.Include) != 2 || len(ec2.TagFilter.Exclude) != 1 {
+		t.Errorf("ec2 options should be loaded from the file, not %+v", ec2)
+	}
+	if rds := cfg.options("rds"); rds.RefreshInterval != 2*time.Hour || len(rds.TagFilter.Exclude) != 0 {
+		t.Errorf("rds options should be overridden by the flags, not %+v", rds)
+	}
+	if elb := cfg.options("elb"); elb.RefreshInterval != 10*time.Minute {
+		t.Errorf("elb refresh interval should be 10m, not %s", elb.RefreshInterval)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		args   []string
+		err    string
+	}{
+		{config: "regoins: [eu-west-1]", err: "field regoins not found"},
+		{config: "include: [ec3]", err: `include: unknown collector "ec3"`},
+		{config: "tags:\n  label_collision: keep", err: "tags.label_collision"},
+		{config: "collectors:\n  ec2:\n    tags:\n      include: ['/[/']", err: "collectors.ec2.tags.include"},
+		{config: "collectors:\n  ec2:\n    resource_types: [s3]", err: "collectors.ec2.resource_types"},
+		{config: "roles:\n  - external_id: secret", err: "roles[0]: arn is required"},
+		{config: "refresh_interval: soon", err: "soon"},
+		{config: "refresh_interval: -1m", err: "collector.refresh-interval must not be negative"},
+		{config: "timeout: -1s", err: "collector.timeout must not be negative"},
+		{config: "page_limit: -1", err: "collector.page-limit must not be negative"},
+		{config: "collectors:\n  ec2:\n    page_limit: -2", err: "collector.page-limits: ec2 must not be negative"},
+		{config: "regions: [eu-west-1, Europe]", err: `invalid region "Europe"`},
+		{args: []string{"-include", "ec3"}, err: `include: unknown collector "ec3"`},
+		{args: []string{"-collector.refresh-intervals", "ec3=1m"}, err: `collector.refresh-intervals: unknown collector "ec3"`},
+	}
+
+	for _, test := range tests {
+		_, err := loadConfig(writeConfig(t, test.config), test.args)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q %v: error should contain %q, not %v", test.config, test.args, test.err, err)
+		}
+	}
+}
@@ -1,25 +1,30 @@
 module github.com/jdbaldry/aws_tags_exporter
 
+go 1.21
+
 require (
 	github.com/aws/aws-sdk-go v1.14.1
+	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
+	github.com/prometheus/client_golang v0.8.0
+	github.com/prometheus/client_model v0.0.0-20171117100541-99fa1f4be8e5
+	golang.org/x/crypto v0.21.0
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
 	github.com/beorn7/perks v0.0.0-20180321164747-3a771d992973 // indirect
 	github.com/go-ini/ini v1.37.0 // indirect
-	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/golang/protobuf v1.1.0 // indirect
 	github.com/gopherjs/gopherjs v0.0.0-20181103185306-d547d1d9531e // indirect
-	github.com/grapeshot/aws_tags_exporter v0.0.0-20180802150535-efa6be0085bd
 	github.com/jmespath/go-jmespath v0.0.0-20160202185014-0b12d6b521d8 // indirect
 	github.com/jtolds/gls v4.2.1+incompatible // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
-	github.com/prometheus/client_golang v0.8.0
-	github.com/prometheus/client_model v0.0.0-20171117100541-99fa1f4be8e5 // indirect
 	github.com/prometheus/common v0.0.0-20180518154759-7600349dcfe1 // indirect
 	github.com/prometheus/procfs v0.0.0-20180601124529-94663424ae5a // indirect
 	github.com/smartystreets/assertions v0.0.0-20180927180507-b2de0cb4f26d // indirect
 	github.com/smartystreets/goconvey v0.0.0-20181108003508-044398e4856c // indirect
 	github.com/stretchr/testify v1.3.0 // indirect
-	golang.org/x/net v0.0.0-20190110200230-915654e7eabc // indirect
-	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4 // indirect
-	golang.org/x/text v0.3.0 // indirect
+	golang.org/x/sync v0.6.0 // indirect
 	gopkg.in/ini.v1 v1.41.0 // indirect
 )
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
+)
+
+// readiness is the body of the /-/ready response.
+type readiness struct {
+	Ready      bool                                  `json:"ready"`
+	Collectors map[string]acollector.CollectorStatus `json:"collectors"`
+}
+
+// readiness returns the status of every registered collector.
+// The exporter is ready once every collector is.
+func (e *exporter) readiness() readiness {
+	collectors, _ := e.active()
+	r := readiness{Ready: true, Collectors: make(map[string]acollector.CollectorStatus, len(collectors))}
+	for name, collector := range collectors {
+		status := collector.Status()
+		r.Ready = r.Ready && status.Ready
+		r.Collectors[name] = status
+	}
+	return r
+}
+
+// healthyHandler reports that the process is alive.
+func healthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("Healthy.\n"))
+	})
+}
+
+// readyHandler reports the status of every collector as JSON.
+// It responds with 503 Service Unavailable until every collector has listed its tags successfully at least once.
+func readyHandler(e *exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readiness := e.readiness()
+		w.Header().Set("Content-Type", "application/json")
+		if !readiness.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(readiness); err != nil {
+			glog.Warningf("Failed to write readiness: %v", err)
+		}
+	})
+}
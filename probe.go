@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// regionRE matches the syntax of AWS region names (e.g. eu-west-1, us-gov-east-1 or eusc-de-east-1).
+// The regions themselves are not checked, so that new regions can be probed without upgrading the SDK.
+var regionRE = regexp.MustCompile(`^[a-z]{2,}(-[a-z]+)+-[0-9]+$`)
+
+// probeTarget returns the account named or identified by account, the options of the collector and the scrape timeout offset.
+// If account is empty, there must only be one account.
+func (e *exporter) probeTarget(collector, account string) (acollector.Account, acollector.Options, time.Duration, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	opts := e.cfg.options(collector)
+	// Probes always list the tags of their target from AWS, without overwriting the health of the configured targets.
+	opts.RefreshInterval = 0
+	opts.DisableHealthMetrics = true
+
+	if account == "" {
+		if len(e.accounts) != 1 {
+			return acollector.Account{}, opts, 0, fmt.Errorf("account is required when there are %d accounts", len(e.accounts))
+		}
+		return e.accounts[0], opts, e.cfg.TimeoutOffset, nil
+	}
+
+	for _, a := range e.accounts {
+		if a.ID == account || (a.Name != "" && a.Name == account) {
+			return a, opts, e.cfg.TimeoutOffset, nil
+		}
+	}
+	return acollector.Account{}, opts, 0, fmt.Errorf("unknown account %q", account)
+}
+
+// probeHandler serves the tags of a single collector in the region and account of the request's
+// collector, region and account parameters, listing them from AWS within the scrape timeout.
+// The region is not required for region agnostic collectors (e.g. Route53).
+func probeHandler(e *exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		name := params.Get("collector")
+		collector, ok := acollector.AvailableCollectors[name]
+		if !ok {
+			available := availableCollectors()
+			http.Error(w, fmt.Sprintf("Unknown collector %q, expected one of %s", name, available.String()), http.StatusBadRequest)
+			return
+		}
+
+		region := params.Get("region")
+		if !collector.Global() {
+			if region == "" {
+				http.Error(w, "The region parameter is required", http.StatusBadRequest)
+				return
+			}
+			if !regionRE.MatchString(region) {
+				http.Error(w, fmt.Sprintf("Invalid region %q", region), http.StatusBadRequest)
+				return
+			}
+		}
+
+		account, opts, offset, err := e.probeTarget(name, params.Get("account"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid account: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		if timeout := scrapeTimeout(r, offset); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		if err := collector.Initialise([]string{region}, []acollector.Account{account}, opts); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to initialise collector %s: %v", name, err), http.StatusInternalServerError)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collector.WithContext(ctx))
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
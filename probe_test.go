@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
+)
+
+func TestProbeHandlerErrors(t *testing.T) {
+	e := newExporter("", nil)
+	e.cfg = newConfig()
+	e.accounts = []acollector.Account{{ID: "123456789012", Name: "prod"}, {ID: "210987654321"}}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{query: "region=eu-west-1&account=prod", err: `Unknown collector ""`},
+		{query: "collector=ec3&region=eu-west-1&account=prod", err: `Unknown collector "ec3"`},
+		{query: "collector=ec2&account=prod", err: "region parameter is required"},
+		{query: "collector=ec2&region=mars-1&account=prod", err: `Invalid region "mars-1"`},
+		{query: "collector=ec2&region=eu-west-1'&account=prod", err: `Invalid region "eu-west-1'"`},
+		// Regions that are newer than the SDK are accepted.
+		{query: "collector=ec2&region=me-south-1", err: "account is required when there are 2 accounts"},
+		{query: "collector=ec2&region=mx-central-1", err: "account is required when there are 2 accounts"},
+		{query: "collector=ec2&region=eusc-de-east-1", err: "account is required when there are 2 accounts"},
+		{query: "collector=ec2&region=eu-west-1", err: "account is required when there are 2 accounts"},
+		{query: "collector=route53&account=staging", err: `unknown account "staging"`},
+	}
+
+	h := probeHandler(e)
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/probe?"+test.query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status code should be %d, not %d", test.query, http.StatusBadRequest, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), test.err) {
+			t.Errorf("%s: body should contain %q, not %q", test.query, test.err, w.Body.String())
+		}
+	}
+}
+
+func TestProbeTarget(t *testing.T) {
+	e := newExporter("", nil)
+	e.cfg = newConfig()
+	e.accounts = []acollector.Account{{ID: "123456789012", Name: "prod"}, {ID: "210987654321"}}
+
+	for account, id := range map[string]string{"prod": "123456789012", "123456789012": "123456789012", "210987654321": "210987654321"} {
+		a, opts, _, err := e.probeTarget("ec2", account)
+		if err != nil {
+			t.Errorf("%s: %v", account, err)
+			continue
+		}
+		if a.ID != id {
+			t.Errorf("%s: account ID should be %s, not %s", account, id, a.ID)
+		}
+		if opts.RefreshInterval != 0 {
+			t.Errorf("%s: probes should not refresh in the background", account)
+		}
+		if !opts.DisableHealthMetrics {
+			t.Errorf("%s: probes should not record the health of the configured targets", account)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ConfigLastReloadSuccessfulMetric reports whether the last configuration reload succeeded
+	ConfigLastReloadSuccessfulMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "aws_tags_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful",
+		},
+	)
+	// ConfigLastReloadSuccessMetric reports when the configuration was last loaded successfully
+	ConfigLastReloadSuccessMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "aws_tags_config_last_reload_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful configuration reload",
+		},
+	)
+)
+
+// exporter holds the registered collectors so that they can be replaced when the configuration is reloaded.
+type exporter struct {
+	configFile string   // configFile is the path of the configuration file, if any
+	args       []string // args are the command line arguments that override the configuration file
+
+	applyMu sync.Mutex // applyMu serialises apply, which resolves the regions and accounts without holding mu
+
+	mu         sync.RWMutex // mu guards the fields below, which are only written by apply
+	cfg        *config
+	regions    regionList
+	accounts   []acollector.Account
+	collectors map[string]*acollector.TagsCollector // collectors are the registered collectors by name
+	options    map[string]acollector.Options        // options are the options each collector was registered with
+
+	enabledRegions func() ([]string, error)                              // enabledRegions returns the regions that "all" expands to
+	newAccounts    func([]acollector.Role) ([]acollector.Account, error) // newAccounts returns the accounts of the roles
+}
+
+func newExporter(configFile string, args []string) *exporter {
+	return &exporter{
+		configFile: configFile,
+		args:       args,
+		collectors: make(map[string]*acollector.TagsCollector),
+		options:    make(map[string]acollector.Options),
+
+		enabledRegions: acollector.EnabledRegions,
+		newAccounts:    acollector.NewAccounts,
+	}
+}
+
+// active returns the registered collectors and the scrape timeout offset.
+func (e *exporter) active() (map[string]*acollector.TagsCollector, time.Duration) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.collectors, e.cfg.TimeoutOffset
+}
+
+// tagsCollectors returns the registered collectors.
+func (e *exporter) tagsCollectors() []*acollector.TagsCollector {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	tcs := make([]*acollector.TagsCollector, 0, len(e.collectors))
+	for _, collector := range e.collectors {
+		tcs = append(tcs, collector)
+	}
+	return tcs
+}
+
+// apply registers the collectors of the configuration in place of those of the previous configuration.
+// Collectors whose settings, regions and accounts have not changed keep running with their cached tags.
+// Collectors whose settings only changed how tags are converted to labels also keep their targets and cached tags.
+// A collector that fails to register keeps its previous settings, if it had any.
+// The health metrics of the regions and accounts that are no longer collected are deleted.
+// If the configuration is invalid or no collector is registered, the previous collectors are kept.
+// The regions and accounts are resolved from AWS before the new collectors are swapped in, so scrapes
+// of the previous collectors are not blocked meanwhile.
+func (e *exporter) apply(cfg *config) error {
+	e.applyMu.Lock()
+	defer e.applyMu.Unlock()
+
+	if len(cfg.Includes) != 0 && len(cfg.Excludes) != 0 {
+		return errors.New("only specify either included or excluded collectors")
+	}
+
+	var cols collectorSet
+	if len(cfg.Includes) != 0 {
+		cols = cfg.Includes
+	} else {
+		cols = getCollectorsAfterExclude(cfg.Excludes)
+	}
+
+	regions, err := cfg.Regions.expand(e.enabledRegions)
+	if err != nil {
+		return err
+	}
+	if len(regions) == 0 && !allCollectorsAreGlobal(cols) {
+		return errors.New("please supply a region")
+	}
+
+	rebuild := e.cfg == nil || !reflect.DeepEqual(regions, e.regions) || !reflect.DeepEqual(cfg.Roles, e.cfg.Roles)
+	accounts := e.accounts
+	if e.cfg == nil || !reflect.DeepEqual(cfg.Roles, e.cfg.Roles) {
+		accounts, err = e.newAccounts(cfg.Roles)
+		if err != nil {
+			return fmt.Errorf("failed to initialise AWS accounts: %v", err)
+		}
+	}
+
+	collectors := make(map[string]*acollector.TagsCollector, len(cols))
+	options := make(map[string]acollector.Options, len(cols))
+	replaced := make(map[string]*acollector.TagsCollector) // replaced are the previous collectors to stop by name
+	var failed []string
+	for c := range cols {
+		collector, ok := acollector.AvailableCollectors[c]
+		if !ok {
+			glog.Warningf("No requested collector: %s", c)
+			continue
+		}
+
+		opts := cfg.options(c)
+		previous, running := e.collectors[c]
+		if running && !rebuild && reflect.DeepEqual(opts, e.options[c]) {
+			collectors[c], options[c] = previous, opts
+			continue
+		}
+		if running && !rebuild && opts.SameListing(e.options[c]) {
+			relabelled, err := previous.WithLabelOptions(opts)
+			if err != nil {
+				glog.Warningf("Failed to update the labels of collector %s: %v", c, err)
+				failed = append(failed, c)
+				collectors[c], options[c] = previous, e.options[c]
+				continue
+			}
+			collectors[c], options[c] = relabelled, opts
+			continue
+		}
+
+		if err := collector.Register(regions, accounts, opts); err != nil {
+			glog.Warningf("Failed to initialise collector: %s", c)
+			glog.Warning(err)
+			failed = append(failed, c)
+			if running {
+				collectors[c], options[c] = previous, e.options[c]
+			}
+			continue
+		}
+
+		if running {
+			replaced[c] = previous
+		}
+		collectors[c], options[c] = &collector, opts
+	}
+
+	if len(collectors) == 0 {
+		return errors.New("no valid collectors specified")
+	}
+
+	for c, previous := range e.collectors {
+		if _, ok := collectors[c]; !ok {
+			replaced[c] = previous
+		}
+	}
+
+	e.mu.Lock()
+	e.cfg, e.regions, e.accounts, e.collectors, e.options = cfg, regions, accounts, collectors, options
+	e.mu.Unlock()
+
+	for c, previous := range replaced {
+		previous.Stop()
+		previous.Retire(collectors[c])
+	}
+
+	names := make([]string, 0, len(collectors))
+	for c := range collectors {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	glog.Infof("Active collectors: %s", strings.Join(names, ","))
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to initialise collectors: %s", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// stop stops the background refreshes of every collector.
+func (e *exporter) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, collector := range e.collectors {
+		collector.Stop()
+	}
+}
+
+// reload loads the configuration file and flags again and applies them.
+// Request limits and web settings other than the scrape timeout offset are only read at startup.
+func (e *exporter) reload() error {
+	cfg, err := loadConfig(e.configFile, e.args)
+	if err == nil {
+		err = e.apply(cfg)
+	}
+
+	if err != nil {
+		ConfigLastReloadSuccessfulMetric.Set(0)
+		glog.Errorf("Failed to reload configuration: %v", err)
+		return err
+	}
+
+	ConfigLastReloadSuccessfulMetric.Set(1)
+	ConfigLastReloadSuccessMetric.Set(float64(time.Now().Unix()))
+	glog.Info("Reloaded configuration")
+	return nil
+}
+
+// reloadOnSignal reloads the configuration every time the process receives SIGHUP.
+func reloadOnSignal(e *exporter) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		_ = e.reload()
+	}
+}
+
+// reloadHandler reloads the configuration on POST requests.
+func reloadHandler(e *exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := e.reload(); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to reload configuration: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
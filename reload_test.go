@@ -0,0 +1,153 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	acollector "github.com/jdbaldry/aws_tags_exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestExporterApply(t *testing.T) {
+	const accountID = "111111111111"
+	tests := []struct {
+		name    string
+		change  func(cfg *config)
+		kept    bool     // kept is true if the ec2 collector should not be replaced
+		removed bool     // removed is true if the ec2 collector should be removed
+		healthy []string // healthy are the regions whose health gauges should be kept
+		err     string
+	}{
+		{name: "unchanged", change: func(cfg *config) {}, kept: true, healthy: []string{"eu-west-1", "us-east-1"}},
+		{name: "label settings", change: func(cfg *config) { cfg.TagPrefix = true }, healthy: []string{"eu-west-1", "us-east-1"}},
+		{name: "listing settings", change: func(cfg *config) { cfg.PageLimit = 10 }, healthy: []string{"eu-west-1", "us-east-1"}},
+		{name: "dropped region", change: func(cfg *config) { cfg.Regions = regionList{"eu-west-1"} }, healthy: []string{"eu-west-1"}},
+		{
+			name:    "rolled back labels",
+			change:  func(cfg *config) { cfg.TagSchema = stringList{"region"} },
+			kept:    true,
+			healthy: []string{"eu-west-1", "us-east-1"},
+			err:     "failed to initialise collectors: ec2",
+		},
+		{
+			name: "rolled back listing",
+			change: func(cfg *config) {
+				cfg.PageLimit = 10
+				cfg.TagSchema = stringList{"region"}
+			},
+			kept:    true,
+			healthy: []string{"eu-west-1", "us-east-1"},
+			err:     "failed to initialise collectors: ec2",
+		},
+		{name: "removed collector", change: func(cfg *config) { cfg.Includes = collectorSet{"rds": {}} }, removed: true},
+		{
+			name:    "include and exclude",
+			change:  func(cfg *config) { cfg.Excludes = collectorSet{"rds": {}} },
+			kept:    true,
+			healthy: []string{"eu-west-1", "us-east-1"},
+			err:     "only specify either included or excluded collectors",
+		},
+	}
+
+	newTestConfig := func() *config {
+		cfg := newConfig()
+		cfg.Includes = collectorSet{"ec2": {}}
+		cfg.Regions = regionList{"eu-west-1", "us-east-1"}
+		// Collectors that list the tags on every scrape do not call AWS until they are collected.
+		cfg.RefreshInterval = 0
+		return cfg
+	}
+
+	for _, test := range tests {
+		e := newExporter("", nil)
+		e.newAccounts = func([]acollector.Role) ([]acollector.Account, error) {
+			return []acollector.Account{{ID: accountID}}, nil
+		}
+		if err := e.apply(newTestConfig()); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		previous, previousOpts := e.collectors["ec2"], e.options["ec2"]
+		for _, region := range []string{"eu-west-1", "us-east-1"} {
+			acollector.CollectorSuccessMetric.WithLabelValues("ec2", region, accountID).Set(1)
+		}
+
+		cfg := newTestConfig()
+		test.change(cfg)
+		err := e.apply(cfg)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error should contain %q, not %v", test.name, test.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+
+		collector, running := e.collectors["ec2"]
+		switch {
+		case test.removed:
+			if running {
+				t.Errorf("%s: the collector should be removed", test.name)
+			}
+		case !running:
+			t.Errorf("%s: the collector should be running", test.name)
+		case test.kept && collector != previous:
+			t.Errorf("%s: the collector should be kept", test.name)
+		case !test.kept && collector == previous:
+			t.Errorf("%s: the collector should be replaced", test.name)
+		}
+		if test.kept && !reflect.DeepEqual(e.options["ec2"], previousOpts) {
+			t.Errorf("%s: the collector should keep its options", test.name)
+		}
+		if !test.kept && !test.removed && !reflect.DeepEqual(e.options["ec2"], cfg.options("ec2")) {
+			t.Errorf("%s: the collector should have the new options", test.name)
+		}
+
+		for _, region := range []string{"eu-west-1", "us-east-1"} {
+			healthy := false
+			for _, r := range test.healthy {
+				healthy = healthy || r == region
+			}
+			labels := prometheus.Labels{"collector": "ec2", "region": region, "account_id": accountID}
+			if deleted := !acollector.CollectorSuccessMetric.Delete(labels); deleted == healthy {
+				t.Errorf("%s: the health of %s should be kept: %t, deleted: %t", test.name, region, healthy, deleted)
+			}
+		}
+		e.stop()
+	}
+}
+
+func TestExporterApplyResolvesUnlocked(t *testing.T) {
+	e := newExporter("", nil)
+	// notBlocked fails the test if the collectors cannot be read while AWS is called
+	notBlocked := func(call string) {
+		done := make(chan struct{})
+		go func() {
+			e.tagsCollectors()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Errorf("Scrapes should not be blocked while %s", call)
+		}
+	}
+	e.enabledRegions = func() ([]string, error) {
+		notBlocked("listing the enabled regions")
+		return []string{"eu-west-1"}, nil
+	}
+	e.newAccounts = func([]acollector.Role) ([]acollector.Account, error) {
+		notBlocked("assuming the roles")
+		return []acollector.Account{{ID: "111111111111"}}, nil
+	}
+
+	cfg := newConfig()
+	cfg.Includes = collectorSet{"ec2": {}}
+	cfg.Regions = regionList{"all"}
+	cfg.RefreshInterval = 0
+	if err := e.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	e.stop()
+}
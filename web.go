@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// tlsVersions maps the TLS versions of the web configuration file to their crypto/tls values
+	tlsVersions = map[string]uint16{
+		"TLS10": tls.VersionTLS10,
+		"TLS11": tls.VersionTLS11,
+		"TLS12": tls.VersionTLS12,
+		"TLS13": tls.VersionTLS13,
+	}
+	// clientAuthTypes maps the client authentication types of the web configuration file to their crypto/tls values
+	clientAuthTypes = map[string]tls.ClientAuthType{
+		"NoClientCert":               tls.NoClientCert,
+		"RequestClientCert":          tls.RequestClientCert,
+		"RequireAnyClientCert":       tls.RequireAnyClientCert,
+		"RequireClientCert":          tls.RequireAnyClientCert, // RequireClientCert is kept by the exporter toolkit for backwards compatibility
+		"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+		"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+	}
+	// curves maps the curves of the web configuration file to their crypto/tls values
+	curves = map[string]tls.CurveID{
+		"CurveP256": tls.CurveP256,
+		"CurveP384": tls.CurveP384,
+		"CurveP521": tls.CurveP521,
+		"X25519":    tls.X25519,
+	}
+	// dummyHash is compared against the passwords of unknown users so that they take as long to reject as known users
+	dummyHash = []byte("$2a$10$.vnThC36q8goIUq4AQRLfehBxy2ROxSli4Plz2ei6wHMhrEzZPoqq")
+)
+
+// webServerConfig is the web configuration file, in the format of the Prometheus exporter toolkit.
+// It configures TLS, basic authentication and rate limiting on the HTTP servers.
+type webServerConfig struct {
+	TLS       tlsServerConfig   `yaml:"tls_server_config"`
+	HTTP      httpServerConfig  `yaml:"http_server_config"`
+	RateLimit rateLimitConfig   `yaml:"rate_limit"`
+	Users     map[string]string `yaml:"basic_auth_users"` // Users maps user names to bcrypt hashes of their passwords
+}
+
+type tlsServerConfig struct {
+	Cert                     string   `yaml:"cert"` // Cert is the PEM encoded certificate, used if there is no cert_file
+	Key                      string   `yaml:"key"`  // Key is the PEM encoded key, used if there is no key_file
+	CertFile                 string   `yaml:"cert_file"`
+	KeyFile                  string   `yaml:"key_file"`
+	ClientAuthType           string   `yaml:"client_auth_type"`
+	ClientCA                 string   `yaml:"client_ca"` // ClientCA are the PEM encoded client CAs, used if there is no client_ca_file
+	ClientCAFile             string   `yaml:"client_ca_file"`
+	ClientAllowedSANs        []string `yaml:"client_allowed_sans"` // ClientAllowedSANs restrict the client certificates to those with any of the SANs
+	MinVersion               string   `yaml:"min_version"`
+	MaxVersion               string   `yaml:"max_version"`
+	CipherSuites             []string `yaml:"cipher_suites"`
+	CurvePreferences         []string `yaml:"curve_preferences"`
+	PreferServerCipherSuites *bool    `yaml:"prefer_server_cipher_suites"` // PreferServerCipherSuites is ignored by crypto/tls
+}
+
+type httpServerConfig struct {
+	HTTP2   *bool             `yaml:"http2"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+type rateLimitConfig struct {
+	Burst    int           `yaml:"burst"`
+	Interval time.Duration `yaml:"interval"` // Interval is the time between requests, 0 disables rate limiting
+}
+
+// loadWebServerConfig loads and validates the web configuration file.
+func loadWebServerConfig(path string) (*webServerConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var wc webServerConfig
+	if err := yaml.UnmarshalStrict(b, &wc); err != nil {
+		return nil, fmt.Errorf("invalid web config file %s: %v", path, err)
+	}
+	if wc.RateLimit.Interval < 0 || wc.RateLimit.Burst < 0 {
+		return nil, fmt.Errorf("invalid web config file %s: rate_limit: interval and burst must not be negative", path)
+	}
+	// Relative paths are relative to the web configuration file, as in the exporter toolkit
+	dir := filepath.Dir(path)
+	for _, file := range []*string{&wc.TLS.CertFile, &wc.TLS.KeyFile, &wc.TLS.ClientCAFile} {
+		if *file != "" && !filepath.IsAbs(*file) {
+			*file = filepath.Join(dir, *file)
+		}
+	}
+	if _, err := wc.tlsConfig(); err != nil {
+		return nil, fmt.Errorf("invalid web config file %s: %v", path, err)
+	}
+	for user, hash := range wc.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid web config file %s: basic_auth_users: %s: %v", path, user, err)
+		}
+	}
+
+	return &wc, nil
+}
+
+// keyPair loads the certificate and key from their files, or from the configuration if they have no file.
+func (c tlsServerConfig) keyPair() (*tls.Certificate, error) {
+	certPEM, keyPEM := []byte(c.Cert), []byte(c.Key)
+	var err error
+	if c.CertFile != "" {
+		if certPEM, err = ioutil.ReadFile(c.CertFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.KeyFile != "" {
+		if keyPEM, err = ioutil.ReadFile(c.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// verifyClientSANs rejects client certificates without any of the allowed SANs.
+func (c tlsServerConfig) verifyClientSANs(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no client certificate to check the allowed SANs of")
+	}
+	// The client's certificate comes first
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %v", err)
+	}
+
+	sans := append(append([]string{}, cert.DNSNames...), cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	for _, san := range sans {
+		for _, allowed := range c.ClientAllowedSANs {
+			if san == allowed {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client certificate has none of the allowed SANs, found: %v", sans)
+}
+
+// tlsConfig returns the TLS configuration of the servers, or nil if they serve plain HTTP.
+// The certificate and key are loaded again on every handshake so that they can be rotated without restarting.
+func (wc *webServerConfig) tlsConfig() (*tls.Config, error) {
+	c := wc.TLS
+	hasCert, hasKey := c.CertFile != "" || c.Cert != "", c.KeyFile != "" || c.Key != ""
+	if !hasCert && !hasKey {
+		if c.ClientCAFile != "" || c.ClientCA != "" || c.ClientAuthType != "" || len(c.ClientAllowedSANs) > 0 {
+			return nil, errors.New("tls_server_config: client authentication requires a certificate and key")
+		}
+		return nil, nil
+	}
+	if !hasCert || !hasKey {
+		return nil, errors.New("tls_server_config: both a certificate (cert or cert_file) and a key (key or key_file) must be set")
+	}
+	if _, err := c.keyPair(); err != nil {
+		return nil, fmt.Errorf("tls_server_config: %v", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.keyPair()
+		},
+	}
+
+	var ok bool
+	if c.MinVersion != "" {
+		if cfg.MinVersion, ok = tlsVersions[c.MinVersion]; !ok {
+			return nil, fmt.Errorf("tls_server_config: unknown min_version %q", c.MinVersion)
+		}
+	}
+	if c.MaxVersion != "" {
+		if cfg.MaxVersion, ok = tlsVersions[c.MaxVersion]; !ok {
+			return nil, fmt.Errorf("tls_server_config: unknown max_version %q", c.MaxVersion)
+		}
+	}
+
+	if len(c.CipherSuites) > 0 {
+		ids := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			ids[suite.Name] = suite.ID
+		}
+		for _, name := range c.CipherSuites {
+			id, ok := ids[name]
+			if !ok {
+				return nil, fmt.Errorf("tls_server_config: unknown cipher suite %q", name)
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+	for _, name := range c.CurvePreferences {
+		curve, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("tls_server_config: unknown curve %q", name)
+		}
+		cfg.CurvePreferences = append(cfg.CurvePreferences, curve)
+	}
+
+	if c.ClientAuthType != "" {
+		if cfg.ClientAuth, ok = clientAuthTypes[c.ClientAuthType]; !ok {
+			return nil, fmt.Errorf("tls_server_config: unknown client_auth_type %q", c.ClientAuthType)
+		}
+	} else if c.ClientCAFile != "" || c.ClientCA != "" {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if c.ClientCAFile != "" || c.ClientCA != "" {
+		b := []byte(c.ClientCA)
+		if c.ClientCAFile != "" {
+			var err error
+			if b, err = ioutil.ReadFile(c.ClientCAFile); err != nil {
+				return nil, fmt.Errorf("tls_server_config: %v", err)
+			}
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(b) {
+			return nil, errors.New("tls_server_config: no certificates found in the client CAs")
+		}
+	} else if cfg.ClientAuth == tls.VerifyClientCertIfGiven || cfg.ClientAuth == tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("tls_server_config: client_auth_type %s requires client_ca or client_ca_file", c.ClientAuthType)
+	}
+	if len(c.ClientAllowedSANs) > 0 {
+		cfg.VerifyPeerCertificate = c.verifyClientSANs
+	}
+
+	return cfg, nil
+}
+
+// secure configures the server to serve TLS and authenticate requests according to the web configuration.
+func (wc *webServerConfig) secure(srv *http.Server) error {
+	cfg, err := wc.tlsConfig()
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = cfg
+	if cfg != nil && wc.HTTP.HTTP2 != nil && !*wc.HTTP.HTTP2 {
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	srv.Handler = wc.handler(srv.Handler)
+	return nil
+}
+
+// handler limits the rate of requests, sets the configured headers on every response and, if there are any users,
+// requires basic authentication.
+func (wc *webServerConfig) handler(next http.Handler) http.Handler {
+	var cache sync.Map // cache holds the digests of the credentials that were already verified
+	var limiter *rate.Limiter
+	if wc.RateLimit.Interval > 0 {
+		limiter = rate.NewLimiter(rate.Every(wc.RateLimit.Interval), wc.RateLimit.Burst)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limiter != nil && !limiter.Allow() {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		for k, v := range wc.HTTP.Headers {
+			w.Header().Set(k, v)
+		}
+		if len(wc.Users) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+		if ok {
+			hash, known := wc.Users[user]
+			if !known {
+				hash = string(dummyHash)
+			}
+
+			// bcrypt is deliberately slow, so credentials are only verified once
+			digest := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+			_, verified := cache.Load(digest)
+			if !verified && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+				cache.Store(digest, struct{}{})
+				verified = true
+			}
+			if verified && known {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="aws_tags_exporter"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its key to temporary files and returns their paths
+// along with the certificate.
+func writeCertificate(t *testing.T) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "aws_tags_exporter"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = writeConfig(t, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	keyFile = writeConfig(t, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return certFile, keyFile, cert
+}
+
+func TestWebServerConfigBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc, err := loadWebServerConfig(writeConfig(t, `
+basic_auth_users:
+  alice: `+string(hash)+`
+http_server_config:
+  headers:
+    X-Frame-Options: deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := wc.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		user, password string
+		code           int
+	}{
+		{code: http.StatusUnauthorized},
+		{user: "alice", password: "wrong", code: http.StatusUnauthorized},
+		{user: "bob", password: "secret", code: http.StatusUnauthorized},
+		{user: "alice", password: "secret", code: http.StatusOK},
+		// The second request is verified from the cache
+		{user: "alice", password: "secret", code: http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.password)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s:%s: status code should be %d, not %d", test.user, test.password, test.code, w.Code)
+		}
+		if w.Header().Get("X-Frame-Options") != "deny" {
+			t.Errorf("%s:%s: the configured headers should be set", test.user, test.password)
+		}
+	}
+}
+
+func TestWebServerConfigMutualTLS(t *testing.T) {
+	certFile, keyFile, cert := writeCertificate(t)
+	wc, err := loadWebServerConfig(writeConfig(t, `
+tls_server_config:
+  cert_file: `+certFile+`
+  key_file: `+keyFile+`
+  client_ca_file: `+certFile+`
+  min_version: TLS12
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	if err := wc.secure(srv); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(ioutil.Discard, "", 0)
+	go srv.ServeTLS(l, "", "")
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, certs := range [][]tls.Certificate{nil, {clientCert}} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		resp, err := client.Get("https://" + l.Addr().String())
+		if len(certs) == 0 {
+			if err == nil {
+				resp.Body.Close()
+				t.Error("Requests without a client certificate should be rejected")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Requests with a client certificate should succeed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Status code should be %d, not %d", http.StatusOK, resp.StatusCode)
+		}
+	}
+}
+
+func TestWebServerConfigAllowedSANs(t *testing.T) {
+	certFile, keyFile, cert := writeCertificate(t)
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	// indent indents the PEM block to nest it in the YAML
+	indent := func(b []byte) string {
+		return strings.ReplaceAll(strings.TrimSpace(string(b)), "\n", "\n    ")
+	}
+	for _, san := range []string{"127.0.0.1", "other.example.com"} {
+		// The certificate, key and client CA are set inline rather than as files
+		wc, err := loadWebServerConfig(writeConfig(t, `
+tls_server_config:
+  cert: |
+    `+indent(certPEM)+`
+  key: |
+    `+indent(keyPEM)+`
+  client_ca: |
+    `+indent(certPEM)+`
+  client_auth_type: RequireAndVerifyClientCert
+  client_allowed_sans: [`+san+`]
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+		if err := wc.secure(srv); err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.ErrorLog = log.New(ioutil.Discard, "", 0)
+		go srv.ServeTLS(l, "", "")
+		defer srv.Close()
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}}}
+		resp, err := client.Get("https://" + l.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		if allowed := san == "127.0.0.1"; allowed != (err == nil) {
+			t.Errorf("%s: requests should be allowed: %t, error: %v", san, allowed, err)
+		}
+	}
+}
+
+func TestWebServerConfigRateLimit(t *testing.T) {
+	wc, err := loadWebServerConfig(writeConfig(t, `
+rate_limit:
+  interval: 1h
+  burst: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := wc.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i, code := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if w.Code != code {
+			t.Errorf("Request %d: status code should be %d, not %d", i, code, w.Code)
+		}
+	}
+}
+
+func TestLoadWebServerConfigRelativePaths(t *testing.T) {
+	certFile, keyFile, _ := writeCertificate(t)
+	dir := filepath.Dir(certFile)
+	keyPath, err := filepath.Rel(dir, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "web.yml")
+	config := "tls_server_config:\n  cert_file: " + filepath.Base(certFile) + "\n  key_file: " + keyPath
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadWebServerConfig(path); err != nil {
+		t.Errorf("Paths relative to the web config file should be loaded: %v", err)
+	}
+}
+
+func TestLoadWebServerConfigErrors(t *testing.T) {
+	certFile, keyFile, _ := writeCertificate(t)
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{config: "basic_auth_user: {}", err: "field basic_auth_user not found"},
+		{config: "basic_auth_users:\n  alice: secret", err: "basic_auth_users: alice"},
+		{config: "tls_server_config:\n  cert_file: " + certFile, err: "both a certificate"},
+		{config: "tls_server_config:\n  client_ca_file: " + certFile, err: "requires a certificate and key"},
+		{config: "tls_server_config:\n  client_allowed_sans: [client]", err: "requires a certificate and key"},
+		{config: "rate_limit:\n  interval: -1s", err: "rate_limit"},
+		{config: "tls_server_config:\n  cert_file: " + certFile + "\n  key_file: " + keyFile + "\n  min_version: TLS14", err: "min_version"},
+		{config: "tls_server_config:\n  cert_file: " + certFile + "\n  key_file: " + keyFile + "\n  client_auth_type: RequireAndVerifyClientCert", err: "requires client_ca or client_ca_file"},
+	}
+
+	for _, test := range tests {
+		_, err := loadWebServerConfig(writeConfig(t, test.config))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: error should contain %q, not %v", test.config, test.err, err)
+		}
+	}
+}